@@ -9,6 +9,17 @@ type Value interface {
 	int | int16 | int32 | int64 | uint | uint16 | uint32 | uint64
 }
 
+// MaxLeaves returns the largest number of leaves the MMR can hold with the index type.
+// Every leaf and node index of the MMR is below its size, so the size is the only limit.
+func MaxLeaves[TI Value]() TI {
+	// Fill the bits from the lowest until the next one would wrap to zero or to a negative value
+	res := TI(1)
+	for next := res<<1 | 1; next > res; next = res<<1 | 1 {
+		res = next
+	}
+	return res
+}
+
 // Index index navigator
 type Index[TI Value] interface {
 	GetHeight() int
@@ -24,6 +35,46 @@ type Index[TI Value] interface {
 	Key() string
 }
 
+// posIndex is the Index over a Pos, the missing positions are returned as nil.
+type posIndex[TI Value] struct {
+	Pos[TI]
+}
+
+func (i *posIndex[TI]) LeftBranch() Index[TI] {
+	return toIndex(i.Pos.LeftBranch())
+}
+
+func (i *posIndex[TI]) GetSibling() Index[TI] {
+	return i.Pos.GetSibling().ToIndex()
+}
+
+func (i *posIndex[TI]) RightUp() Index[TI] {
+	return toIndex(i.Pos.RightUp())
+}
+
+func (i *posIndex[TI]) Up() Index[TI] {
+	return toIndex(i.Pos.Up())
+}
+
+func (i *posIndex[TI]) Top() Index[TI] {
+	return i.Pos.Top().ToIndex()
+}
+
+func (i *posIndex[TI]) Children() []Index[TI] {
+	left, right, ok := i.Pos.Children()
+	if !ok {
+		return nil
+	}
+	return []Index[TI]{left.ToIndex(), right.ToIndex()}
+}
+
+func toIndex[TI Value](p Pos[TI], ok bool) Index[TI] {
+	if !ok {
+		return nil
+	}
+	return p.ToIndex()
+}
+
 // GetPeaks Calculates Peaks
 // Algorithm:
 //  1. Get Top from the current position
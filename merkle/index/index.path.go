@@ -0,0 +1,51 @@
+package index
+
+import "math/bits"
+
+// LeafRange returns the first and the last leaf covered by the leaf or node.
+// A node of height h is in the middle of its 2^(h+1) leaves.
+func LeafRange[TI Value](p Pos[TI]) (first, last TI) {
+	if p.leaf {
+		return p.value, p.value
+	}
+	half := TI(1) << p.GetHeight()
+	return p.value - half, p.value + half - 1
+}
+
+// PeakFor returns the peak which covers the leaf in the MMR of size leaves, ok is false if the leaf is out of the MMR.
+// It is the peak of PeaksForSize which covers the leaf, found without building the others.
+func PeakFor[TI Value](leaf, size TI) (peak Peak[TI], ok bool) {
+	if leaf < 0 || leaf >= size {
+		return peak, false
+	}
+	// Every set bit of the size is a peak, the leaf is below the one at the highest bit where it differs from the size
+	height := bits.Len64(uint64(leaf^size)) - 1
+	return peakAt(size>>(height+1)<<(height+1), height), true
+}
+
+// PathToPeak returns the leaf and its ancestors up to its peak in the MMR of size leaves,
+// the leaf first and the peak last. It is nil if the leaf is out of the MMR.
+func PathToPeak[TI Value](leaf, size TI) []Pos[TI] {
+	peak, ok := PeakFor(leaf, size)
+	if !ok {
+		return nil
+	}
+	res := make([]Pos[TI], 0, peak.Height+1)
+	p := LeafPos(leaf)
+	res = append(res, p)
+	for range peak.Height {
+		p, _ = p.Up()
+		res = append(res, p)
+	}
+	return res
+}
+
+// CommonAncestor returns the lowest leaf or node which covers both positions, it is one of them if it covers the other.
+func CommonAncestor[TI Value](a, b Pos[TI]) Pos[TI] {
+	firstA, lastA := LeafRange(a)
+	firstB, lastB := LeafRange(b)
+	first, last := min(firstA, firstB), max(lastA, lastB)
+	// The ends differ only below the bit height, so both are in one subtree of 2^height leaves
+	height := bits.Len64(uint64(first ^ last))
+	return subtreeTop(first>>height<<height, height)
+}
@@ -0,0 +1,77 @@
+package index_test
+
+import (
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLeafRange(t *testing.T) {
+	first, last := index.LeafRange(index.LeafPos[uint32](5))
+	assert.Equal(t, []uint32{5, 5}, []uint32{first, last})
+	first, last = index.LeafRange(index.NodePos[uint32](4))
+	assert.Equal(t, []uint32{0, 7}, []uint32{first, last})
+	first, last = index.LeafRange(index.NodePos[uint32](9))
+	assert.Equal(t, []uint32{8, 9}, []uint32{first, last})
+}
+
+func TestPeakFor(t *testing.T) {
+	_, ok := index.PeakFor[int32](11, 11)
+	assert.False(t, ok)
+	_, ok = index.PeakFor[int32](-1, 11)
+	assert.False(t, ok)
+
+	for size := int64(1); size <= 300; size++ {
+		for _, expected := range index.PeaksForSize(size) {
+			for leaf := expected.FirstLeaf; leaf <= expected.LastLeaf; leaf++ {
+				peak, ok := index.PeakFor(leaf, size)
+				assert.True(t, ok)
+				assert.Equal(t, expected, peak, "peak of leaf %d of size %d", leaf, size)
+
+				path := index.PathToPeak(leaf, size)
+				assert.Len(t, path, expected.Height+1)
+				assert.Equal(t, index.LeafPos(leaf), path[0])
+				assert.Equal(t, index.PosOf(expected.Index), path[len(path)-1])
+				for _, p := range path {
+					first, last := index.LeafRange(p)
+					assert.True(t, first <= leaf && leaf <= last, "%s should cover the leaf %d", p.Key(), leaf)
+				}
+			}
+		}
+	}
+	assert.Nil(t, index.PathToPeak[int64](3, 3))
+
+	maxLeaves := index.MaxLeaves[int16]()
+	peak, ok := index.PeakFor(0, maxLeaves)
+	assert.True(t, ok)
+	assert.Equal(t, index.PeaksForSize(maxLeaves)[14], peak)
+	peak, ok = index.PeakFor(maxLeaves-1, maxLeaves)
+	assert.True(t, ok)
+	assert.Equal(t, index.PeaksForSize(maxLeaves)[0], peak)
+}
+
+func TestCommonAncestor(t *testing.T) {
+	assert.Equal(t, index.NodePos[uint16](4), index.CommonAncestor(index.LeafPos[uint16](3), index.LeafPos[uint16](4)))
+	assert.Equal(t, index.NodePos[uint16](1), index.CommonAncestor(index.LeafPos[uint16](1), index.LeafPos[uint16](0)))
+	assert.Equal(t, index.LeafPos[uint16](6), index.CommonAncestor(index.LeafPos[uint16](6), index.LeafPos[uint16](6)))
+	assert.Equal(t, index.NodePos[uint16](6), index.CommonAncestor(index.NodePos[uint16](6), index.LeafPos[uint16](5)))
+
+	// The lowest ancestor of a which covers b, found by walking up from a
+	for a := uint64(0); a < 64; a++ {
+		for _, pa := range []index.Pos[uint64]{index.LeafPos(a), index.NodePos(a + 1)} {
+			for b := uint64(0); b < 64; b++ {
+				pb := index.LeafPos(b)
+				expected := pa
+				for {
+					first, last := index.LeafRange(expected)
+					if first <= b && b <= last {
+						break
+					}
+					expected, _ = expected.Up()
+				}
+				assert.Equal(t, expected, index.CommonAncestor(pa, pb), "%s and %s", pa.Key(), pb.Key())
+				assert.Equal(t, expected, index.CommonAncestor(pb, pa))
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package index
+
+import "math/bits"
+
+// Peak is a peak of the MMR with the range of the leaves it covers.
+type Peak[TI Value] struct {
+	Index Index[TI]
+	// Height is the number of the levels below the peak, the peak covers 2^Height leaves: 0 for a leaf,
+	// 1 for a node of two leaves and so on. It is not Index.GetHeight, which is 0 for the node of two leaves,
+	// so a node peak has Index.GetHeight() == Height-1.
+	Height    int
+	FirstLeaf TI
+	LastLeaf  TI
+}
+
+// PeaksForSize calculates the peaks of the MMR of size leaves in the same order as GetPeaks, the peak of the latest leaf first.
+// Every set bit of the size is a peak of 2^bit leaves, so the layout is known without walking the tree.
+func PeaksForSize[TI Value](size TI) []Peak[TI] {
+	if size <= 0 {
+		return nil
+	}
+	res := make([]Peak[TI], 0, bits.OnesCount64(uint64(size)))
+	end := size
+	for rest := uint64(size); rest != 0; rest &= rest - 1 {
+		height := bits.TrailingZeros64(rest)
+		end -= TI(1) << height
+		res = append(res, peakAt(end, height))
+	}
+	return res
+}
+
+// peakAt returns the peak of 2^height leaves starting at the leaf start.
+func peakAt[TI Value](start TI, height int) Peak[TI] {
+	return Peak[TI]{Index: subtreeTop(start, height).ToIndex(), Height: height, FirstLeaf: start, LastLeaf: start + TI(1)<<height - 1}
+}
+
+// subtreeTop returns the top of the perfect tree of 2^height leaves starting at the leaf start, the leaf itself for height 0.
+func subtreeTop[TI Value](start TI, height int) Pos[TI] {
+	if height == 0 {
+		return LeafPos(start)
+	}
+	return NodePos(start + TI(1)<<(height-1))
+}
@@ -0,0 +1,34 @@
+package index
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPeaksForSize(t *testing.T) {
+	assert.Empty(t, PeaksForSize[int](0))
+	assert.Empty(t, PeaksForSize[int](-3))
+
+	peaks := PeaksForSize[uint32](11)
+	assert.Len(t, peaks, 3)
+	assert.Equal(t, Peak[uint32]{Index: LeafIndex[uint32](10), Height: 0, FirstLeaf: 10, LastLeaf: 10}, peaks[0])
+	assert.Equal(t, Peak[uint32]{Index: NodeIndex[uint32](9), Height: 1, FirstLeaf: 8, LastLeaf: 9}, peaks[1])
+	assert.Equal(t, Peak[uint32]{Index: NodeIndex[uint32](4), Height: 3, FirstLeaf: 0, LastLeaf: 7}, peaks[2])
+
+	for size := int64(1); size <= 1100; size++ {
+		peaks := PeaksForSize(size)
+		expected := GetPeaks(LeafIndex(size - 1))
+		assert.Len(t, peaks, len(expected))
+		end := size
+		for i, p := range peaks {
+			assert.Equal(t, expected[i].Key(), p.Index.Key(), "peak %d of size %d", i, size)
+			assert.Equal(t, end-1, p.LastLeaf)
+			assert.Equal(t, int64(1)<<p.Height, p.LastLeaf-p.FirstLeaf+1)
+			if !p.Index.IsLeaf() {
+				assert.Equal(t, p.Height-1, p.Index.GetHeight(), "height of the node peak %d of size %d", i, size)
+			}
+			end = p.FirstLeaf
+		}
+		assert.Equal(t, int64(0), end, "peaks of size %d should cover all the leaves", size)
+	}
+}
@@ -0,0 +1,156 @@
+package index
+
+import (
+	"fmt"
+)
+
+// Pos is a leaf or a node of the MMR held by value. It has the navigation of Index as pure arithmetic,
+// so walking the tree does not allocate. A missing position is reported by the ok result instead of nil.
+// Visualization:
+//
+//	         [4] (height 2)
+//	          |
+//	 [2] (height 1)      [6] (height 1)
+//	/   \                /    \
+//
+// [1]     [3]            [5]    [7] (height 0)
+// / \      / \           / \    /  \
+// 0   1    2   3       4   5   6    7
+type Pos[TI Value] struct {
+	value TI
+	leaf  bool
+}
+
+// LeafPos returns the position of the leaf.
+func LeafPos[TI Value](value TI) Pos[TI] {
+	return Pos[TI]{value: value, leaf: true}
+}
+
+// NodePos returns the position of the node.
+func NodePos[TI Value](value TI) Pos[TI] {
+	return Pos[TI]{value: value}
+}
+
+// PosOf returns the position of the index.
+func PosOf[TI Value](i Index[TI]) Pos[TI] {
+	return Pos[TI]{value: i.Index(), leaf: i.IsLeaf()}
+}
+
+// Index returns the index value of the position.
+func (p Pos[TI]) Index() TI {
+	return p.value
+}
+
+// IsLeaf checks if the position is a leaf.
+func (p Pos[TI]) IsLeaf() bool {
+	return p.leaf
+}
+
+// GetHeight returns 0 for a leaf and for the nodes above the leaves, 1 one level up and so on.
+func (p Pos[TI]) GetHeight() int {
+	if p.leaf {
+		return 0
+	}
+	return getHeight(p.value)
+}
+
+// IsRight checks if the position is a right child: an odd leaf, or a node of height h with the bit h+1 set.
+func (p Pos[TI]) IsRight() bool {
+	if p.leaf {
+		return p.value&1 == 1
+	}
+	distance := TI(2) << p.GetHeight()
+	return p.value&distance == distance
+}
+
+// GetSibling returns the other child of the parent.
+func (p Pos[TI]) GetSibling() Pos[TI] {
+	if p.leaf {
+		if p.IsRight() {
+			return LeafPos(p.value - 1)
+		}
+		return LeafPos(p.value + 1)
+	}
+	return NodePos(p.value ^ TI(2)<<p.GetHeight())
+}
+
+// RightUp returns the parent if the position is a right child.
+func (p Pos[TI]) RightUp() (Pos[TI], bool) {
+	if !p.IsRight() {
+		return Pos[TI]{}, false
+	}
+	if p.leaf {
+		return NodePos(p.value), true
+	}
+	if value := p.value ^ TI(1)<<p.GetHeight(); value > 0 {
+		return NodePos(value), true
+	}
+	return Pos[TI]{}, false
+}
+
+// Up returns the parent of the position.
+func (p Pos[TI]) Up() (Pos[TI], bool) {
+	if !p.IsRight() {
+		p = p.GetSibling()
+	}
+	return p.RightUp()
+}
+
+// LeftBranch returns the top of the tree on the left of the position, if there is one.
+func (p Pos[TI]) LeftBranch() (Pos[TI], bool) {
+	if p.leaf {
+		if !p.IsRight() && p.value != 0 {
+			return NodePos(p.value - 1), true
+		}
+		return Pos[TI]{}, false
+	}
+	if distance := TI(2) << p.GetHeight(); p.value > distance {
+		return NodePos(p.value - distance), true
+	}
+	return Pos[TI]{}, false
+}
+
+// Top returns the highest ancestor reached by the right children only.
+func (p Pos[TI]) Top() Pos[TI] {
+	if p.leaf {
+		if !p.IsRight() {
+			return p
+		}
+		return NodePos(p.value).Top()
+	}
+	shift := TI(1) << p.GetHeight()
+	value := p.value
+	top := value
+	for top != 0 && value&shift == shift {
+		top = value
+		value ^= shift
+		shift <<= 1
+	}
+	return NodePos(top)
+}
+
+// Children returns the children of a node, a leaf has none.
+func (p Pos[TI]) Children() (left, right Pos[TI], ok bool) {
+	if p.leaf {
+		return left, right, false
+	}
+	height := p.GetHeight()
+	if height == 0 {
+		return LeafPos(p.value - 1), LeafPos(p.value), true
+	}
+	distance := TI(1) << (height - 1)
+	return NodePos(p.value - distance), NodePos(p.value + distance), true
+}
+
+// Key returns the text key of the position, the same as Index.Key.
+func (p Pos[TI]) Key() string {
+	if p.leaf {
+		return fmt.Sprintf("leaf_%d", p.value)
+	}
+	return fmt.Sprintf("node_%d", p.value)
+}
+
+// ToIndex returns the Index of the position.
+func (p Pos[TI]) ToIndex() Index[TI] {
+	return &posIndex[TI]{Pos: p}
+}
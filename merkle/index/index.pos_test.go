@@ -0,0 +1,96 @@
+package index_test
+
+import (
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPos_Navigation(t *testing.T) {
+	leaf := index.LeafPos[uint32](5)
+	assert.True(t, leaf.IsLeaf())
+	assert.True(t, leaf.IsRight())
+	assert.Equal(t, index.LeafPos[uint32](4), leaf.GetSibling())
+	up, ok := leaf.Up()
+	assert.True(t, ok)
+	assert.Equal(t, index.NodePos[uint32](5), up)
+
+	up, ok = up.Up()
+	assert.True(t, ok)
+	assert.Equal(t, index.NodePos[uint32](6), up)
+	_, ok = up.RightUp()
+	assert.True(t, ok)
+
+	_, ok = index.LeafPos[uint32](4).RightUp()
+	assert.False(t, ok, "left leaf should not have a right parent")
+	_, ok = index.NodePos[uint32](4).RightUp()
+	assert.False(t, ok, "there should be no parent above the top")
+
+	branch, ok := index.NodePos[uint32](6).LeftBranch()
+	assert.True(t, ok)
+	assert.Equal(t, index.NodePos[uint32](2), branch)
+	_, ok = index.LeafPos[uint32](0).LeftBranch()
+	assert.False(t, ok)
+
+	assert.Equal(t, index.NodePos[uint32](4), index.LeafPos[uint32](7).Top())
+	assert.Equal(t, index.LeafPos[uint32](6), index.LeafPos[uint32](6).Top())
+
+	_, _, ok = leaf.Children()
+	assert.False(t, ok, "leaf should not have children")
+	left, right, ok := index.NodePos[uint32](1).Children()
+	assert.True(t, ok)
+	assert.Equal(t, index.LeafPos[uint32](0), left)
+	assert.Equal(t, index.LeafPos[uint32](1), right)
+}
+
+func TestPos_IndexLayer(t *testing.T) {
+	same := func(i index.Index[int64], p index.Pos[int64], ok bool) {
+		if i == nil {
+			assert.False(t, ok)
+			return
+		}
+		assert.True(t, ok)
+		assert.Equal(t, i.Key(), p.Key())
+		assert.Equal(t, p, index.PosOf(i))
+	}
+	for v := int64(0); v < 1100; v++ {
+		for _, p := range []index.Pos[int64]{index.LeafPos(v), index.NodePos(v)} {
+			i := p.ToIndex()
+			assert.Equal(t, i.GetHeight(), p.GetHeight())
+			assert.Equal(t, i.IsRight(), p.IsRight())
+			same(i.GetSibling(), p.GetSibling(), true)
+			same(i.Top(), p.Top(), true)
+			up, ok := p.Up()
+			same(i.Up(), up, ok)
+			up, ok = p.RightUp()
+			same(i.RightUp(), up, ok)
+			branch, ok := p.LeftBranch()
+			same(i.LeftBranch(), branch, ok)
+
+			left, right, ok := p.Children()
+			if children := i.Children(); len(children) == 2 {
+				same(children[0], left, ok)
+				same(children[1], right, ok)
+			} else {
+				assert.False(t, ok)
+			}
+			if v > 0 && ok {
+				assert.Equal(t, right, left.GetSibling())
+				parent, _ := left.Up()
+				assert.Equal(t, p, parent, "left child of %s", p.Key())
+				parent, _ = right.Up()
+				assert.Equal(t, p, parent, "right child of %s", p.Key())
+			}
+		}
+	}
+}
+
+func TestPos_NoAllocations(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		for p, ok := index.LeafPos[uint64](1023), true; ok; p, ok = p.Up() {
+			_ = p.GetSibling()
+			_ = p.Top()
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
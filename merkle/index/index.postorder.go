@@ -0,0 +1,64 @@
+package index
+
+import "math/bits"
+
+// The post-order numbering used by Grin, ckb and most papers counts the leaves and the nodes
+// in the order they are appended, starting from 0:
+//
+//	      6
+//	    /   \
+//	   2     5     9
+//	  / \   / \   / \
+//	 0   1 3   4 7   8  10
+//
+// The height is 0 for a leaf, 1 for a node of two leaves and so on, so a node of this package
+// with the height h has the post-order height h+1.
+
+// LeafCountToMMRSize returns the number of the leaves and the nodes of the MMR of the given leaves.
+func LeafCountToMMRSize[TI Value](leaves TI) TI {
+	return 2*leaves - TI(bits.OnesCount64(uint64(leaves)))
+}
+
+// MMRSizeToLeafCount returns the number of the leaves of the MMR of size leaves and nodes,
+// ok is false if no MMR has this size.
+func MMRSizeToLeafCount[TI Value](size TI) (leaves TI, ok bool) {
+	rest := uint64(size)
+	for height := bits.Len64(rest) - 1; height >= 0; height-- {
+		if peak := uint64(1)<<(height+1) - 1; peak <= rest {
+			rest -= peak
+			leaves += TI(1) << height
+		}
+	}
+	return leaves, rest == 0
+}
+
+// PostOrder returns the post-order position and height of the leaf or node.
+func (p Pos[TI]) PostOrder() (pos TI, height int) {
+	if p.leaf {
+		return LeafCountToMMRSize(p.value), 0
+	}
+	height = p.GetHeight() + 1
+	last := p.value + TI(1)<<(height-1) - 1
+	return LeafCountToMMRSize(last) + TI(height), height
+}
+
+// PostOrderHeight returns the height of the post-order position.
+func PostOrderHeight[TI Value](pos TI) int {
+	// Jump to the left sibling until the position is the peak of a perfect tree, its size is all ones
+	n := uint64(pos) + 1
+	for n&(n+1) != 0 {
+		n -= uint64(1)<<(bits.Len64(n)-1) - 1
+	}
+	return bits.Len64(n) - 1
+}
+
+// PosFromPostOrder returns the leaf or node of the post-order position.
+func PosFromPostOrder[TI Value](pos TI) Pos[TI] {
+	height := PostOrderHeight(pos)
+	// The last leaf below the position is height positions before it, all the leaves before it make up an MMR
+	last, _ := MMRSizeToLeafCount(pos - TI(height))
+	if height == 0 {
+		return LeafPos(last)
+	}
+	return NodePos(last + 1 - TI(1)<<height + TI(1)<<(height-1))
+}
@@ -0,0 +1,66 @@
+package index_test
+
+import (
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// postOrderNode is a leaf or node of the reference MMR, numbered by the order of the appends.
+type postOrderNode struct {
+	pos, height int
+	first, last int
+}
+
+// postOrderReference appends the leaves to a post-order MMR one by one, merging the equal peaks.
+func postOrderReference(leaves int) (nodes []postOrderNode, sizes []int) {
+	var peaks []postOrderNode
+	sizes = append(sizes, 0)
+	for leaf := 0; leaf < leaves; leaf++ {
+		current := postOrderNode{pos: len(nodes), first: leaf, last: leaf}
+		nodes = append(nodes, current)
+		for len(peaks) > 0 && peaks[len(peaks)-1].height == current.height {
+			left := peaks[len(peaks)-1]
+			peaks = peaks[:len(peaks)-1]
+			current = postOrderNode{pos: len(nodes), height: current.height + 1, first: left.first, last: current.last}
+			nodes = append(nodes, current)
+		}
+		peaks = append(peaks, current)
+		sizes = append(sizes, len(nodes))
+	}
+	return nodes, sizes
+}
+
+func TestPostOrder(t *testing.T) {
+	assert.Equal(t, uint32(11), index.LeafCountToMMRSize[uint32](7))
+	pos, height := index.NodePos[uint32](2).PostOrder()
+	assert.Equal(t, uint32(6), pos)
+	assert.Equal(t, 2, height)
+	assert.Equal(t, index.LeafPos[uint32](6), index.PosFromPostOrder[uint32](10))
+
+	nodes, sizes := postOrderReference(1100)
+	valid := make(map[int]bool)
+	for leaves, size := range sizes {
+		assert.Equal(t, int64(size), index.LeafCountToMMRSize(int64(leaves)))
+		count, ok := index.MMRSizeToLeafCount(int64(size))
+		assert.True(t, ok)
+		assert.Equal(t, int64(leaves), count)
+		valid[size] = true
+	}
+	for size := 0; size < len(nodes); size++ {
+		_, ok := index.MMRSizeToLeafCount(uint64(size))
+		assert.Equal(t, valid[size], ok, "size %d", size)
+	}
+
+	for _, n := range nodes {
+		expected := index.LeafPos(uint64(n.first))
+		if n.height > 0 {
+			expected = index.NodePos(uint64(n.first) + uint64(1)<<(n.height-1))
+		}
+		pos, height := expected.PostOrder()
+		assert.Equal(t, uint64(n.pos), pos, "position of %s", expected.Key())
+		assert.Equal(t, n.height, height, "height of %s", expected.Key())
+		assert.Equal(t, n.height, index.PostOrderHeight(uint64(n.pos)))
+		assert.Equal(t, expected, index.PosFromPostOrder(uint64(n.pos)), "post-order position %d", n.pos)
+	}
+}
@@ -99,3 +99,24 @@ func TestPeaks(t *testing.T) {
 	assert.Equal(t, 10, peaks14[2].Index())
 	assert.Equal(t, 4, peaks14[3].Index())
 }
+
+func TestMaxLeaves(t *testing.T) {
+	assert.Equal(t, uint16(65535), MaxLeaves[uint16]())
+	assert.Equal(t, int16(32767), MaxLeaves[int16]())
+	assert.Equal(t, int32(2147483647), MaxLeaves[int32]())
+	assert.Equal(t, uint32(4294967295), MaxLeaves[uint32]())
+	assert.Equal(t, int64(9223372036854775807), MaxLeaves[int64]())
+	assert.Equal(t, ^uint64(0), MaxLeaves[uint64]())
+}
+
+func TestNegativeIndex(t *testing.T) {
+	// The constructors stay valid for every input, the negative values are rejected by the callers
+	for _, x := range []Index[int16]{LeafIndex[int16](-1), NodeIndex[int16](-4)} {
+		if assert.NotNil(t, x) {
+			assert.NotPanics(t, func() {
+				x.Top().GetSibling().Key()
+				x.Children()
+			})
+		}
+	}
+}
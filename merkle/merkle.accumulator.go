@@ -0,0 +1,167 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"slices"
+	"sync"
+)
+
+var ErrInvalidAccumulator = errors.New("invalid accumulator")
+
+// ICompactAccumulator keeps only the peaks of the MMR, O(log n) hashes, so stateless validators can
+// append the leaves and calculate the same root as the full MMR without storing the nodes.
+type ICompactAccumulator[TI index.Value, TH types.HashType] interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	Append(values ...TH) error
+	Root() (IRoot[TI, TH], error)
+	// Peaks returns the peak hashes in the order of index.GetPeaks, the peak of the latest leaf first.
+	Peaks() []TH
+	Size() TI
+}
+
+type compactAccumulator[TI index.Value, TH types.HashType] struct {
+	sync.RWMutex
+	hf   types.Hasher[TH]
+	size TI
+	// peaks are ordered from the leftmost peak to the latest one.
+	peaks []TH
+}
+
+// NewCompactAccumulator creates an empty accumulator, restore a serialized one with UnmarshalBinary.
+func NewCompactAccumulator[TI index.Value, TH types.HashType](hf types.Hasher[TH]) ICompactAccumulator[TI, TH] {
+	return &compactAccumulator[TI, TH]{hf: hf}
+}
+
+// NewAccumulatorFromPeaks creates an accumulator of size leaves from its peaks in the order of index.GetPeaks,
+// e.g. the old peaks of an append proof.
+func NewAccumulatorFromPeaks[TI index.Value, TH types.HashType](hf types.Hasher[TH], size TI, peaks []TH) (ICompactAccumulator[TI, TH], error) {
+	if size < 0 || len(peaks) != peakCount(size) {
+		return nil, ErrInvalidAccumulator
+	}
+	res := &compactAccumulator[TI, TH]{
+		hf:    hf,
+		size:  size,
+		peaks: slices.Clone(peaks),
+	}
+	slices.Reverse(res.peaks)
+	return res, nil
+}
+
+// peakCount returns the number of the peaks of the MMR of size leaves.
+func peakCount[TI index.Value](size TI) int {
+	if size <= 0 {
+		return 0
+	}
+	return len(index.GetPeaks(index.LeafIndex(size - 1)))
+}
+
+// Append adds the leaves. Every right child completes its parent, so the peaks on the RightUp chain
+// of the new leaf are merged with it the same way the full MMR builds the nodes.
+// Like Add of the MMR, it returns *CapacityError and adds nothing when the size would exceed index.MaxLeaves.
+func (a *compactAccumulator[TI, TH]) Append(values ...TH) error {
+	a.Lock()
+	defer a.Unlock()
+	if maxLeaves := index.MaxLeaves[TI](); uint64(len(values)) > uint64(maxLeaves-a.size) {
+		return &CapacityError[TI]{Size: a.size, Adding: len(values), MaxLeaves: maxLeaves}
+	}
+	size := a.size
+	peaks := slices.Clone(a.peaks)
+	for _, v := range values {
+		current := v
+		for i, ok := index.LeafPos(size).RightUp(); ok; i, ok = i.RightUp() {
+			sibling := peaks[len(peaks)-1]
+			peaks = peaks[:len(peaks)-1]
+			var err error
+			if current, err = hashNode(a.hf, sibling, current); err != nil {
+				return err
+			}
+		}
+		peaks = append(peaks, current)
+		size++
+	}
+	a.size, a.peaks = size, peaks
+	return nil
+}
+
+func (a *compactAccumulator[TI, TH]) Root() (IRoot[TI, TH], error) {
+	r, err := bagPeaks(a.hf, a.Peaks())
+	if err != nil {
+		return nil, err
+	}
+	return newRoot[TI, TH](r, a.hf), nil
+}
+
+func (a *compactAccumulator[TI, TH]) Peaks() []TH {
+	a.RLock()
+	defer a.RUnlock()
+	res := slices.Clone(a.peaks)
+	slices.Reverse(res)
+	return res
+}
+
+func (a *compactAccumulator[TI, TH]) Size() TI {
+	a.RLock()
+	defer a.RUnlock()
+	return a.size
+}
+
+// MarshalBinary encodes the size as uvarint followed by the length prefixed peaks, the leftmost peak first.
+func (a *compactAccumulator[TI, TH]) MarshalBinary() ([]byte, error) {
+	a.RLock()
+	defer a.RUnlock()
+	res := binary.AppendUvarint(nil, uint64(a.size))
+	for _, p := range a.peaks {
+		data, err := types.HashBytes(p)
+		if err != nil {
+			return nil, err
+		}
+		res = binary.AppendUvarint(res, uint64(len(data)))
+		res = append(res, data...)
+	}
+	return res, nil
+}
+
+// UnmarshalBinary restores the accumulator, the number of peaks must match index.GetPeaks of the size.
+func (a *compactAccumulator[TI, TH]) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewReader(data)
+	size, err := binary.ReadUvarint(buf)
+	if err != nil || TI(size) < 0 || uint64(TI(size)) != size {
+		return ErrInvalidAccumulator
+	}
+	count := peakCount(TI(size))
+
+	var zero TH
+	zeroData, err := types.HashBytes(zero)
+	if err != nil {
+		return err
+	}
+	_, variable := any(zero).(string)
+	peaks := make([]TH, 0, count)
+	for range count {
+		n, rErr := binary.ReadUvarint(buf)
+		if rErr != nil || n > uint64(buf.Len()) || (!variable && n != uint64(len(zeroData))) {
+			return ErrInvalidAccumulator
+		}
+		peak := make([]byte, n)
+		_, _ = buf.Read(peak)
+		h, hErr := types.BufferRead[TH](bytes.NewReader(peak))
+		if hErr != nil && n > 0 {
+			return hErr
+		}
+		peaks = append(peaks, h)
+	}
+	if buf.Len() > 0 {
+		return ErrInvalidAccumulator
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.size, a.peaks = TI(size), peaks
+	return nil
+}
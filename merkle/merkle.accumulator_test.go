@@ -0,0 +1,90 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompactAccumulator_MatchesMountainRange(t *testing.T) {
+	ctx := context.Background()
+	memoryIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memoryIndexes)
+	acc := merkle.NewCompactAccumulator[uint64, types.Hash256](hasher.Sha3_256)
+
+	for i := 0; i < 70; i++ {
+		h := hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+		assert.NoError(t, m.Add(ctx, h))
+		assert.NoError(t, acc.Append(h))
+		assert.Equal(t, m.Size(), acc.Size())
+
+		var peaks []types.Hash256
+		for _, p := range index.GetPeaks(index.LeafIndex(m.Size() - 1)) {
+			peak, err := memoryIndexes.Get(ctx, p.IsLeaf(), p.Index())
+			assert.NoError(t, err)
+			peaks = append(peaks, peak)
+		}
+		assert.Equal(t, peaks, acc.Peaks(), "peaks of size %d", m.Size())
+
+		root, err := m.Root(ctx)
+		assert.NoError(t, err)
+		accRoot, err := acc.Root()
+		assert.NoError(t, err)
+		assert.Equal(t, root.Hash(), accRoot.Hash(), "root of size %d", m.Size())
+
+		// The root of the accumulator validates the proofs of the full MMR
+		proof, err := m.ProofByIndex(ctx, uint64(i/2))
+		assert.NoError(t, err)
+		assert.True(t, accRoot.ValidateProof(proof))
+	}
+}
+
+func TestCompactAccumulator_Serialization(t *testing.T) {
+	acc := merkle.NewCompactAccumulator[uint32, types.Hash256](hasher.Sha3_256)
+	var hashes []types.Hash256
+	for i := 0; i < 23; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, acc.Append(hashes[:11]...))
+
+	data, err := acc.MarshalBinary()
+	assert.NoError(t, err)
+	restored := merkle.NewCompactAccumulator[uint32, types.Hash256](hasher.Sha3_256)
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, acc.Size(), restored.Size())
+	assert.Equal(t, acc.Peaks(), restored.Peaks())
+
+	assert.NoError(t, acc.Append(hashes[11:]...))
+	assert.NoError(t, restored.Append(hashes[11:]...))
+	root, err := acc.Root()
+	assert.NoError(t, err)
+	restoredRoot, err := restored.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, root.Hash(), restoredRoot.Hash())
+
+	invalid := merkle.NewCompactAccumulator[uint32, types.Hash256](hasher.Sha3_256)
+	assert.ErrorIs(t, invalid.UnmarshalBinary(data[:len(data)-1]), merkle.ErrInvalidAccumulator)
+	assert.ErrorIs(t, invalid.UnmarshalBinary(append(data, 0)), merkle.ErrInvalidAccumulator)
+	assert.ErrorIs(t, invalid.UnmarshalBinary([]byte{1, 2, 0, 0}), merkle.ErrInvalidAccumulator, "peak of a wrong width")
+	assert.Equal(t, uint32(0), invalid.Size())
+}
+
+func TestCompactAccumulator_StringHashes(t *testing.T) {
+	hf := func(values ...[]byte) string {
+		return fmt.Sprintf("%x", hasher.Sha3_256(values...))
+	}
+	acc := merkle.NewCompactAccumulator[int, string](hf)
+	assert.NoError(t, acc.Append("a", "bb", "", "dddd", "e"))
+
+	data, err := acc.MarshalBinary()
+	assert.NoError(t, err)
+	restored := merkle.NewCompactAccumulator[int, string](hf)
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, acc.Peaks(), restored.Peaks())
+}
@@ -0,0 +1,73 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+var ErrInvalidAppendProof = errors.New("append proof is invalid")
+
+// AppendProof creates a proof that the leaves from oldSize to newSize were appended to the MMR of oldSize leaves.
+// The appends don't change the nodes below the size, so the old peaks can be read for any historical size.
+// Only the appends after a Truncate rewrite them, the proof is created again if a Truncate runs meanwhile.
+func (m *mmr[TIndex, THash]) AppendProof(ctx context.Context, oldSize, newSize TIndex) (*AppendProof[TIndex, THash], error) {
+	return readStable(m, func(size TIndex, _ uint64) (*AppendProof[TIndex, THash], error) {
+		return m.appendProof(ctx, size, oldSize, newSize)
+	})
+}
+
+// appendProof creates the proof for the sizes within the given size of the MMR.
+func (m *mmr[TIndex, THash]) appendProof(ctx context.Context, size, oldSize, newSize TIndex) (*AppendProof[TIndex, THash], error) {
+	if oldSize < 0 || oldSize > newSize || newSize > size {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	var indexes []index.Index[TIndex]
+	if oldSize > 0 {
+		indexes = index.GetPeaks(index.LeafIndex(oldSize - 1))
+	}
+	peakCount := len(indexes)
+	for i := oldSize; i < newSize; i++ {
+		indexes = append(indexes, index.LeafIndex(i))
+	}
+	hashes, err := m.indexToHash(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+	return &AppendProof[TIndex, THash]{
+		OldSize:  oldSize,
+		OldPeaks: hashes[:peakCount:peakCount],
+		Leaves:   hashes[peakCount:],
+	}, nil
+}
+
+// VerifyAppend checks the old peaks of the proof against the old root and calculates the root
+// of the MMR with the appended leaves, without access to the MMR itself.
+func VerifyAppend[TI index.Value, TH types.HashType](hf types.Hasher[TH], oldRoot TH, proof *AppendProof[TI, TH]) (IRoot[TI, TH], error) {
+	if proof == nil {
+		return nil, ErrInvalidAppendProof
+	}
+	acc, err := NewAccumulatorFromPeaks(hf, proof.OldSize, proof.OldPeaks)
+	if err != nil {
+		return nil, ErrInvalidAppendProof
+	}
+	old, err := acc.Root()
+	if err != nil {
+		return nil, err
+	}
+	if old.Hash() != oldRoot {
+		return nil, ErrInvalidAppendProof
+	}
+	if err = acc.Append(proof.Leaves...); err != nil {
+		return nil, err
+	}
+	return acc.Root()
+}
+
+// ValidateAppend checks that the current root is the root of the MMR with the oldRoot after appending the leaves of the proof.
+func (r *root[TI, TH]) ValidateAppend(oldRoot TH, proof *AppendProof[TI, TH]) bool {
+	newRoot, err := VerifyAppend(r.hf, oldRoot, proof)
+	return err == nil && newRoot.Hash() == r.hash
+}
@@ -0,0 +1,83 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAppendProof_AllSizes(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	roots := map[uint64]types.Hash256{0: hasher.Sha3_256()}
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+		root, err := m.Root(ctx)
+		assert.NoError(t, err)
+		roots[m.Size()] = root.Hash()
+	}
+
+	for oldSize := uint64(0); oldSize <= 25; oldSize++ {
+		for newSize := oldSize; newSize <= 25; newSize++ {
+			proof, err := m.AppendProof(ctx, oldSize, newSize)
+			assert.NoError(t, err)
+			assert.Len(t, proof.Leaves, int(newSize-oldSize))
+
+			newRoot, err := merkle.VerifyAppend(hasher.Sha3_256, roots[oldSize], proof)
+			assert.NoError(t, err, "append %d -> %d", oldSize, newSize)
+			assert.Equal(t, roots[newSize], newRoot.Hash(), "append %d -> %d", oldSize, newSize)
+
+			claimed := merkle.NewRoot[uint64, types.Hash256](hasher.Sha3_256, roots[newSize])
+			assert.True(t, claimed.ValidateAppend(roots[oldSize], proof), "append %d -> %d", oldSize, newSize)
+		}
+	}
+}
+
+func TestAppendProof_Invalid(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	oldRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+	for i := 7; i < 12; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	newRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+
+	_, err = m.AppendProof(ctx, 5, 13)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	_, err = m.AppendProof(ctx, 6, 5)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+
+	proof, err := m.AppendProof(ctx, 7, 12)
+	assert.NoError(t, err)
+	assert.True(t, newRoot.ValidateAppend(oldRoot.Hash(), proof))
+	assert.False(t, newRoot.ValidateAppend(newRoot.Hash(), proof), "old peaks should be proven by the old root")
+	assert.False(t, newRoot.ValidateAppend(oldRoot.Hash(), nil))
+
+	tampered := *proof
+	tampered.Leaves = append([]types.Hash256{}, proof.Leaves...)
+	tampered.Leaves[2] = types.Hash256{1}
+	assert.False(t, newRoot.ValidateAppend(oldRoot.Hash(), &tampered), "tampered leaf")
+
+	tampered = *proof
+	tampered.Leaves = proof.Leaves[:4]
+	assert.False(t, newRoot.ValidateAppend(oldRoot.Hash(), &tampered), "missing leaf")
+
+	tampered = *proof
+	tampered.OldPeaks = proof.OldPeaks[1:]
+	_, err = merkle.VerifyAppend(hasher.Sha3_256, oldRoot.Hash(), &tampered)
+	assert.ErrorIs(t, err, merkle.ErrInvalidAppendProof, "peaks do not match the old size")
+
+	tampered = *proof
+	tampered.OldSize = 8
+	assert.False(t, newRoot.ValidateAppend(oldRoot.Hash(), &tampered), "wrong old size")
+}
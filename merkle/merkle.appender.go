@@ -0,0 +1,179 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAppendWindow is how long the appender waits for more values after the first one of a group.
+	DefaultAppendWindow = time.Millisecond
+	// DefaultMaxAppendBatch is the maximal number of values the appender adds at once.
+	DefaultMaxAppendBatch = 1024
+)
+
+var ErrAppenderClosed = errors.New("appender is closed")
+
+// IAppender collects the values submitted concurrently and adds them to the MMR in groups,
+// so the write lock is taken once per group instead of once per value.
+type IAppender[TIndex index.Value, THash types.HashType] interface {
+	// Submit queues the value, it blocks while the queue is full until ctx is done.
+	Submit(ctx context.Context, value THash) (IAppendFuture[TIndex, THash], error)
+	// Close stops accepting the values and waits until the queued ones are added or ctx is done.
+	Close(ctx context.Context) error
+}
+
+// IAppendFuture is resolved when the group of the submitted value is added.
+type IAppendFuture[TIndex index.Value, THash types.HashType] interface {
+	// Done is closed when the result is ready.
+	Done() <-chan struct{}
+	// Wait returns the result, or ctx.Err() if ctx is done first.
+	Wait(ctx context.Context) (*AppendResult[TIndex, THash], error)
+}
+
+// AppendResult is the leaf index of the submitted value and the receipt of the group it was added with.
+type AppendResult[TIndex index.Value, THash types.HashType] struct {
+	Index   TIndex
+	Receipt *Receipt[TIndex, THash]
+}
+
+type appendFuture[TIndex index.Value, THash types.HashType] struct {
+	done chan struct{}
+	res  *AppendResult[TIndex, THash]
+	err  error
+}
+
+func (f *appendFuture[TIndex, THash]) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *appendFuture[TIndex, THash]) Wait(ctx context.Context) (*AppendResult[TIndex, THash], error) {
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type appendRequest[TIndex index.Value, THash types.HashType] struct {
+	value  THash
+	future *appendFuture[TIndex, THash]
+}
+
+type appender[TIndex index.Value, THash types.HashType] struct {
+	m        IMountainRange[TIndex, THash]
+	window   time.Duration
+	maxBatch int
+	queue    chan appendRequest[TIndex, THash]
+	// closeMu guards closed, Submit holds it for reading while it waits for room in the queue.
+	closeMu sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+}
+
+// NewAppender starts the appender of the MMR. A group is added when maxBatch values are collected
+// or window has passed since its first value. Submit blocks when queueSize values are waiting.
+// The zero or negative arguments are replaced by the defaults, queueSize defaults to 4*maxBatch.
+func NewAppender[TIndex index.Value, THash types.HashType](m IMountainRange[TIndex, THash], window time.Duration, maxBatch, queueSize int) IAppender[TIndex, THash] {
+	if window <= 0 {
+		window = DefaultAppendWindow
+	}
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxAppendBatch
+	}
+	if queueSize <= 0 {
+		queueSize = 4 * maxBatch
+	}
+	a := &appender[TIndex, THash]{
+		m:        m,
+		window:   window,
+		maxBatch: maxBatch,
+		queue:    make(chan appendRequest[TIndex, THash], queueSize),
+		stopped:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *appender[TIndex, THash]) Submit(ctx context.Context, value THash) (IAppendFuture[TIndex, THash], error) {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return nil, ErrAppenderClosed
+	}
+	req := appendRequest[TIndex, THash]{value: value, future: &appendFuture[TIndex, THash]{done: make(chan struct{})}}
+	select {
+	case a.queue <- req:
+		return req.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *appender[TIndex, THash]) Close(ctx context.Context) error {
+	a.closeMu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.closeMu.Unlock()
+
+	select {
+	case <-a.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects the groups until the queue is closed and drained.
+func (a *appender[TIndex, THash]) run() {
+	defer close(a.stopped)
+	timer := time.NewTimer(a.window)
+	timer.Stop()
+	for {
+		req, ok := <-a.queue
+		if !ok {
+			return
+		}
+		group := []appendRequest[TIndex, THash]{req}
+		timer.Reset(a.window)
+	collect:
+		for len(group) < a.maxBatch {
+			select {
+			case req, ok = <-a.queue:
+				if !ok {
+					break collect
+				}
+				group = append(group, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		a.commit(group)
+	}
+}
+
+// commit adds the group and resolves its futures. The group is shared by the callers, so it is added
+// without their contexts: a caller which stops waiting does not remove its value.
+func (a *appender[TIndex, THash]) commit(group []appendRequest[TIndex, THash]) {
+	values := make([]THash, len(group))
+	for i, req := range group {
+		values[i] = req.value
+	}
+	receipt, err := a.m.Append(context.Background(), values...)
+	for i, req := range group {
+		if err != nil {
+			req.future.err = err
+		} else {
+			req.future.res = &AppendResult[TIndex, THash]{Index: receipt.Indexes[i], Receipt: receipt}
+		}
+		close(req.future.done)
+	}
+}
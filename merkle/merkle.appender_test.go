@@ -0,0 +1,160 @@
+package merkle_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingRange holds every Append until release is closed.
+type blockingRange struct {
+	merkle.IMountainRange[uint64, types.Hash256]
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingRange) Append(ctx context.Context, values ...types.Hash256) (*merkle.Receipt[uint64, types.Hash256], error) {
+	<-b.release
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.IMountainRange.Append(ctx, values...)
+}
+
+func TestAppender_ConcurrentSubmits(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	a := merkle.NewAppender[uint64, types.Hash256](m, 5*time.Millisecond, 64, 0)
+
+	const submitters, values = 8, 100
+	results := make([][]*merkle.AppendResult[uint64, types.Hash256], submitters)
+	var wg sync.WaitGroup
+	for s := 0; s < submitters; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < values; i++ {
+				f, err := a.Submit(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("submitter %d data %d", s, i))))
+				if !assert.NoError(t, err) {
+					return
+				}
+				res, err := f.Wait(ctx)
+				if assert.NoError(t, err) {
+					results[s] = append(results[s], res)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.NoError(t, a.Close(ctx))
+	assert.Equal(t, uint64(submitters*values), m.Size())
+
+	seen := make(map[uint64]bool)
+	for s, list := range results {
+		for i, res := range list {
+			assert.False(t, seen[res.Index], "index %d should be returned once", res.Index)
+			seen[res.Index] = true
+			assert.LessOrEqual(t, len(res.Receipt.Indexes), 64, "group should not exceed the maximal batch")
+			assert.Contains(t, res.Receipt.Indexes, res.Index)
+
+			leaf, err := m.Get(ctx, res.Index)
+			assert.NoError(t, err)
+			assert.Equal(t, hasher.Sha3_256([]byte(fmt.Sprintf("submitter %d data %d", s, i))), leaf)
+		}
+	}
+	assert.Len(t, seen, submitters*values)
+}
+
+func TestAppender_GroupsValues(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	a := merkle.NewAppender[uint64, types.Hash256](m, time.Hour, 10, 0)
+
+	var futures []merkle.IAppendFuture[uint64, types.Hash256]
+	for i := 0; i < 25; i++ {
+		f, err := a.Submit(ctx, types.Hash256{byte(i)})
+		assert.NoError(t, err)
+		futures = append(futures, f)
+	}
+	// Two full groups are added without waiting for the window, Close flushes the rest
+	for _, f := range futures[:20] {
+		res, err := f.Wait(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, res.Receipt.Indexes, 10)
+	}
+	assert.NoError(t, a.Close(ctx))
+	for i, f := range futures[20:] {
+		res, err := f.Wait(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(20+i), res.Index)
+		assert.Equal(t, uint64(25), res.Receipt.Size)
+		assert.True(t, res.Receipt.Root.ValidateProof(mustProof(t, m, res.Index)))
+	}
+
+	_, err := a.Submit(ctx, types.Hash256{1})
+	assert.ErrorIs(t, err, merkle.ErrAppenderClosed)
+	assert.NoError(t, a.Close(ctx), "second Close should not fail")
+}
+
+func TestAppender_Backpressure(t *testing.T) {
+	ctx := context.Background()
+	m := &blockingRange{
+		IMountainRange: merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]()),
+		release:        make(chan struct{}),
+	}
+	a := merkle.NewAppender[uint64, types.Hash256](m, time.Millisecond, 1, 1)
+
+	first, err := a.Submit(ctx, types.Hash256{1})
+	assert.NoError(t, err)
+	// The second value waits for room until the first one is taken by the blocked group
+	_, err = a.Submit(ctx, types.Hash256{2})
+	assert.NoError(t, err)
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = a.Submit(timeout, types.Hash256{3})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "full queue should block the submission")
+
+	_, err = first.Wait(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(m.release)
+	res, err := first.Wait(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), res.Index)
+	assert.NoError(t, a.Close(ctx))
+	assert.Equal(t, uint64(2), m.Size())
+}
+
+func TestAppender_Error(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("append failed")
+	m := &blockingRange{
+		IMountainRange: merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]()),
+		release:        make(chan struct{}),
+		err:            failed,
+	}
+	close(m.release)
+	a := merkle.NewAppender[uint64, types.Hash256](m, time.Millisecond, 0, 0)
+
+	f, err := a.Submit(ctx, types.Hash256{1})
+	assert.NoError(t, err)
+	_, err = f.Wait(ctx)
+	assert.ErrorIs(t, err, failed)
+	<-f.Done()
+	assert.NoError(t, a.Close(ctx))
+}
+
+func mustProof(t *testing.T, m merkle.IMountainRange[uint64, types.Hash256], i uint64) *merkle.Proof[uint64, types.Hash256] {
+	p, err := m.ProofByIndex(context.Background(), i)
+	assert.NoError(t, err)
+	return p
+}
@@ -0,0 +1,93 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// roundTripIndexSource counts the calls to the wrapped source, batch calls are counted as a single call.
+type roundTripIndexSource struct {
+	store.IIndexSource[uint64, types.Hash256]
+	batch     bool
+	gets      int
+	sets      int
+	batchGets int
+	batchSets int
+}
+
+func (s *roundTripIndexSource) Get(ctx context.Context, isLeaf bool, i uint64) (types.Hash256, error) {
+	s.gets++
+	return s.IIndexSource.Get(ctx, isLeaf, i)
+}
+
+func (s *roundTripIndexSource) Set(ctx context.Context, isLeaf bool, i uint64, value types.Hash256) error {
+	s.sets++
+	return s.IIndexSource.Set(ctx, isLeaf, i, value)
+}
+
+// batchIndexSource additionally implements the batch extensions.
+type batchIndexSource struct {
+	*roundTripIndexSource
+}
+
+func (s batchIndexSource) GetMany(ctx context.Context, keys []store.Key[uint64]) ([]types.Hash256, error) {
+	s.batchGets++
+	return store.GetMany(ctx, s.IIndexSource, keys)
+}
+
+func (s batchIndexSource) SetMany(ctx context.Context, entries []store.Entry[uint64, types.Hash256]) error {
+	s.batchSets++
+	return store.SetMany(ctx, s.IIndexSource, entries)
+}
+
+func testBatchMmr(t *testing.T, indexes store.IIndexSource[uint64, types.Hash256]) (merkle.IMountainRange[uint64, types.Hash256], merkle.IRoot[uint64, types.Hash256]) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, indexes)
+
+	var hashes []types.Hash256
+	for i := 0; i < 21; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes[:10]...))
+	assert.NoError(t, m.Add(ctx, hashes[10:]...))
+
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	return m, root
+}
+
+func TestMmrBatch_UsesBatchExtensions(t *testing.T) {
+	ctx := context.Background()
+	counter := &roundTripIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256]()}
+	m, root := testBatchMmr(t, batchIndexSource{counter})
+
+	assert.Equal(t, 2, counter.batchSets, "every Add should write with a single SetMany")
+	assert.Equal(t, 0, counter.sets, "Set should not be used when SetMany is available")
+	assert.Equal(t, 0, counter.gets, "Get should not be used when GetMany is available")
+
+	counter.batchGets = 0
+	p, err := m.ProofByIndex(ctx, 12)
+	assert.NoError(t, err)
+	assert.True(t, root.ValidateProof(p))
+	assert.Equal(t, 1, counter.batchGets, "proof should be read with a single GetMany")
+}
+
+func TestMmrBatch_Fallback(t *testing.T) {
+	ctx := context.Background()
+	counter := &roundTripIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256]()}
+	m, root := testBatchMmr(t, counter)
+
+	_, expected := testBatchMmr(t, store.MemoryIndexSource[uint64, types.Hash256]())
+	assert.Equal(t, expected.Hash(), root.Hash(), "fallback should build the same MMR")
+	assert.Greater(t, counter.sets, 21)
+
+	p, err := m.ProofByIndex(ctx, 20)
+	assert.NoError(t, err)
+	assert.True(t, root.ValidateProof(p))
+}
@@ -0,0 +1,23 @@
+package merkle
+
+import (
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// CapacityError is returned by Add and by the accumulator Append when the size would exceed index.MaxLeaves of TIndex, nothing is added.
+// It matches types.ErrCapacityExceeded with errors.Is.
+type CapacityError[TIndex index.Value] struct {
+	Size      TIndex
+	Adding    int
+	MaxLeaves TIndex
+}
+
+func (e *CapacityError[TIndex]) Error() string {
+	return fmt.Sprintf("capacity exceeded: %d leaves can't be added to %d, the maximum is %d", e.Adding, e.Size, e.MaxLeaves)
+}
+
+func (e *CapacityError[TIndex]) Is(target error) bool {
+	return target == types.ErrCapacityExceeded
+}
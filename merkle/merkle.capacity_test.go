@@ -0,0 +1,93 @@
+package merkle_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMountainRange_Capacity(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[int16, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[int16, types.Hash256]())
+
+	// The large batches take the parallel path up to the last leaf
+	maxLeaves := index.MaxLeaves[int16]()
+	values := make([]types.Hash256, maxLeaves-1)
+	for i := range values {
+		values[i] = types.Hash256{byte(i), byte(i >> 8)}
+	}
+	assert.NoError(t, m.Add(ctx, values[:20000]...))
+	assert.NoError(t, m.Add(ctx, values[20000:]...))
+
+	err := m.Add(ctx, types.Hash256{1}, types.Hash256{2})
+	assert.ErrorIs(t, err, types.ErrCapacityExceeded)
+	var capacity *merkle.CapacityError[int16]
+	if assert.ErrorAs(t, err, &capacity) {
+		assert.Equal(t, merkle.CapacityError[int16]{Size: maxLeaves - 1, Adding: 2, MaxLeaves: maxLeaves}, *capacity)
+	}
+	assert.Equal(t, maxLeaves-1, m.Size(), "nothing should be added")
+
+	assert.NoError(t, m.Add(ctx, types.Hash256{1}))
+	_, err = m.Append(ctx, types.Hash256{2})
+	assert.ErrorIs(t, err, types.ErrCapacityExceeded)
+
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	for _, i := range []int16{0, 20000, maxLeaves - 2, maxLeaves - 1} {
+		p, err := m.ProofByIndex(ctx, i)
+		assert.NoError(t, err)
+		assert.True(t, root.ValidateProof(p), "proof of the leaf %d", i)
+	}
+}
+
+func TestCompactAccumulator_Capacity(t *testing.T) {
+	a := merkle.NewCompactAccumulator[int16, types.Hash256](hasher.Sha3_256)
+	maxLeaves := index.MaxLeaves[int16]()
+	values := make([]types.Hash256, maxLeaves)
+	for i := range values {
+		values[i] = types.Hash256{byte(i), byte(i >> 8)}
+	}
+	assert.NoError(t, a.Append(values[:maxLeaves-1]...))
+	root, err := a.Root()
+	assert.NoError(t, err)
+
+	err = a.Append(values[maxLeaves-1], values[0])
+	assert.ErrorIs(t, err, types.ErrCapacityExceeded)
+	var capacity *merkle.CapacityError[int16]
+	if assert.ErrorAs(t, err, &capacity) {
+		assert.Equal(t, merkle.CapacityError[int16]{Size: maxLeaves - 1, Adding: 2, MaxLeaves: maxLeaves}, *capacity)
+	}
+	assert.Equal(t, maxLeaves-1, a.Size(), "nothing should be added")
+	unchanged, err := a.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, root.Hash(), unchanged.Hash())
+
+	assert.NoError(t, a.Append(values[maxLeaves-1]))
+	assert.ErrorIs(t, a.Append(values[0]), types.ErrCapacityExceeded, "size should not wrap to a negative value")
+	assert.Equal(t, maxLeaves, a.Size())
+}
+
+func TestMountainRange_NegativeIndexes(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[int16, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[int16, types.Hash256]())
+	assert.NoError(t, m.Add(ctx, types.Hash256{1}, types.Hash256{2}, types.Hash256{3}))
+
+	_, err := m.Get(ctx, -1)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	_, err = m.ProofByIndex(ctx, -1)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	_, err = m.ConsistencyProof(ctx, -1, 3)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	_, err = m.AppendProof(ctx, -1, 3)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	assert.ErrorIs(t, m.Truncate(ctx, -1), types.ErrIndexOutOfRange)
+
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	assert.False(t, root.ValidateConsistency(root.Hash(), &merkle.ConsistencyProof[int16, types.Hash256]{OldSize: -1, NewSize: 3}))
+}
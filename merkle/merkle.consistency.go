@@ -0,0 +1,95 @@
+package merkle
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// ConsistencyProof creates a proof that the MMR of oldSize leaves is a prefix of the MMR of newSize leaves.
+// Both sizes must be within the current size of the MMR. The appends don't change the nodes below the size,
+// so the proof can be created for any pair of historical sizes. Only the appends after a Truncate rewrite them,
+// the proof is created again if a Truncate runs meanwhile.
+func (m *mmr[TIndex, THash]) ConsistencyProof(ctx context.Context, oldSize, newSize TIndex) (*ConsistencyProof[TIndex, THash], error) {
+	return readStable(m, func(size TIndex, _ uint64) (*ConsistencyProof[TIndex, THash], error) {
+		return m.consistencyProof(ctx, size, oldSize, newSize)
+	})
+}
+
+// consistencyProof creates the proof for the sizes within the given size of the MMR.
+func (m *mmr[TIndex, THash]) consistencyProof(ctx context.Context, size, oldSize, newSize TIndex) (*ConsistencyProof[TIndex, THash], error) {
+	if oldSize <= 0 || oldSize > newSize || newSize > size {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	proof := &ConsistencyProof[TIndex, THash]{
+		OldSize: oldSize,
+		NewSize: newSize,
+	}
+
+	oldPeaks := index.GetPeaks(index.LeafIndex(oldSize - 1))
+	known := make(map[string]struct{}, len(oldPeaks))
+	for _, p := range oldPeaks {
+		known[p.Key()] = struct{}{}
+	}
+
+	var proofIndexes []index.Index[TIndex]
+	for _, p := range index.GetPeaks(index.LeafIndex(newSize - 1)) {
+		proofIndexes = collectConsistencyIndexes(p, oldSize, known, proofIndexes)
+	}
+
+	var err error
+	if proof.OldPeaks, err = m.indexToHash(ctx, oldPeaks); err != nil {
+		return nil, err
+	}
+	if proof.Hashes, err = m.indexToHash(ctx, proofIndexes); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// collectConsistencyIndexes walks down from the new peak and collects the subtrees built only from the appended leaves.
+// The walk stops at the old peaks, which are known to the verifier.
+func collectConsistencyIndexes[TI index.Value](i index.Index[TI], oldSize TI, known map[string]struct{}, res []index.Index[TI]) []index.Index[TI] {
+	if _, ok := known[i.Key()]; ok {
+		return res
+	}
+	if first, _ := index.LeafRange(index.PosOf(i)); first >= oldSize {
+		return append(res, i)
+	}
+	for _, ch := range i.Children() {
+		res = collectConsistencyIndexes(ch, oldSize, known, res)
+	}
+	return res
+}
+
+// rebuildConsistencyNode calculates the hash of the node from the old peaks and the proof hashes,
+// walking the tree in the same order as collectConsistencyIndexes.
+func rebuildConsistencyNode[TI index.Value, TH types.HashType](hf types.Hasher[TH], i index.Index[TI], oldSize TI, known map[string]TH, hashes *[]TH) (res TH, ok bool) {
+	if h, found := known[i.Key()]; found {
+		return h, true
+	}
+	if first, _ := index.LeafRange(index.PosOf(i)); first >= oldSize {
+		if len(*hashes) == 0 {
+			return res, false
+		}
+		res = (*hashes)[0]
+		*hashes = (*hashes)[1:]
+		return res, true
+	}
+
+	children := i.Children()
+	if len(children) != 2 {
+		return res, false
+	}
+	left, ok := rebuildConsistencyNode(hf, children[0], oldSize, known, hashes)
+	if !ok {
+		return res, false
+	}
+	right, ok := rebuildConsistencyNode(hf, children[1], oldSize, known, hashes)
+	if !ok {
+		return res, false
+	}
+	res, err := hashNode(hf, left, right)
+	return res, err == nil
+}
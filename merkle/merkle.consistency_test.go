@@ -0,0 +1,77 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConsistencyProof_AllSizes(t *testing.T) {
+	ctx := context.Background()
+	memoryIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memoryIndexes)
+
+	const maxSize = 33
+	roots := make([]types.Hash256, maxSize+1)
+	for i := 1; i <= maxSize; i++ {
+		h := hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+		assert.NoError(t, m.Add(ctx, h))
+		root, err := m.Root(ctx)
+		assert.NoError(t, err)
+		roots[i] = root.Hash()
+	}
+
+	for oldSize := uint64(1); oldSize <= maxSize; oldSize++ {
+		for newSize := oldSize; newSize <= maxSize; newSize++ {
+			proof, err := m.ConsistencyProof(ctx, oldSize, newSize)
+			if !assert.NoError(t, err, "consistency proof %d -> %d", oldSize, newSize) {
+				continue
+			}
+			newRoot := merkle.NewRoot[uint64, types.Hash256](hasher.Sha3_256, roots[newSize])
+			assert.True(t, newRoot.ValidateConsistency(roots[oldSize], proof), "consistency %d -> %d should be valid", oldSize, newSize)
+		}
+	}
+}
+
+func TestConsistencyProof_Invalid(t *testing.T) {
+	ctx := context.Background()
+	memoryIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memoryIndexes)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	oldRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+
+	for i := 5; i < 11; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	newRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+
+	_, err = m.ConsistencyProof(ctx, 0, 11)
+	assert.Error(t, err, "old size 0 should be rejected")
+	_, err = m.ConsistencyProof(ctx, 5, 12)
+	assert.Error(t, err, "new size beyond the MMR should be rejected")
+
+	proof, err := m.ConsistencyProof(ctx, 5, 11)
+	assert.NoError(t, err)
+	assert.True(t, newRoot.ValidateConsistency(oldRoot.Hash(), proof))
+
+	assert.False(t, newRoot.ValidateConsistency(types.Hash256{1, 2, 3}, proof), "wrong old root should fail")
+	assert.False(t, oldRoot.ValidateConsistency(oldRoot.Hash(), proof), "wrong new root should fail")
+
+	tampered := *proof
+	tampered.Hashes = append([]types.Hash256{}, proof.Hashes...)
+	tampered.Hashes[0] = types.Hash256{9, 9, 9}
+	assert.False(t, newRoot.ValidateConsistency(oldRoot.Hash(), &tampered), "tampered hashes should fail")
+
+	tampered.Hashes = append(append([]types.Hash256{}, proof.Hashes...), types.Hash256{1})
+	assert.False(t, newRoot.ValidateConsistency(oldRoot.Hash(), &tampered), "extra hashes should fail")
+}
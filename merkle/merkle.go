@@ -6,22 +6,47 @@ import (
 	"github.com/dk-open/go-mmr/merkle/index"
 	"github.com/dk-open/go-mmr/store"
 	"github.com/dk-open/go-mmr/types"
+	"iter"
+	"math/bits"
 	"sync"
+	"sync/atomic"
 )
 
 type IMountainRange[TIndex index.Value, THash types.HashType] interface {
 	Add(ctx context.Context, values ...THash) error
+	AddIfSize(ctx context.Context, expectedSize TIndex, values ...THash) error
+	// Append adds the values and returns their indexes with the new size and root, computed under the same lock.
+	Append(ctx context.Context, values ...THash) (*Receipt[TIndex, THash], error)
+	// AppendWithProofs is Append which also returns the inclusion proofs of the values.
+	AppendWithProofs(ctx context.Context, values ...THash) (*Receipt[TIndex, THash], error)
 	Get(ctx context.Context, index TIndex) (THash, error)
+	ScanLeaves(ctx context.Context, from, to TIndex, fn func(i TIndex, leaf THash) bool) error
+	Leaves(ctx context.Context, from, to TIndex) iter.Seq2[TIndex, THash]
 	ProofByIndex(ctx context.Context, index TIndex) (*Proof[TIndex, THash], error)
 	Proof(ctx context.Context, item THash) (*Proof[TIndex, THash], error)
+	ConsistencyProof(ctx context.Context, oldSize, newSize TIndex) (*ConsistencyProof[TIndex, THash], error)
+	AppendProof(ctx context.Context, oldSize, newSize TIndex) (*AppendProof[TIndex, THash], error)
 	Root(ctx context.Context) (IRoot[TIndex, THash], error)
+	Peaks(ctx context.Context) ([]Peak[TIndex, THash], error)
+	// Truncate removes the leaves from size on, the next appends continue from size.
+	Truncate(ctx context.Context, size TIndex) error
+	// Snapshot captures the current size with its peaks, the reads of the snapshot do not block the appends.
+	Snapshot(ctx context.Context) (ISnapshot[TIndex, THash], error)
 	Size() TIndex
 }
 
 type mmr[TIndex index.Value, THash types.HashType] struct {
 	sync.RWMutex
 	//root    THash
-	size    TIndex
+	size TIndex
+	// version is the number of the successful appends, snapshots report it.
+	version uint64
+	// epoch is increased by Truncate and when the size is replaced from the store, the nodes above
+	// the previous size may be rewritten after it.
+	// It is written under the write lock and read without the lock by the reads which check it, see checkEpoch.
+	epoch atomic.Uint64
+	// cache keeps the peaks of the latest size, nil until they are read after the open or the truncation.
+	cache   atomic.Pointer[peakCache[TIndex, THash]]
 	hf      types.Hasher[THash]
 	indexes store.IIndexSource[TIndex, THash]
 }
@@ -34,40 +59,115 @@ func NewMountainRange[TIndex index.Value, THash types.HashType](hf types.Hasher[
 	}
 }
 
-func (m *mmr[TIndex, THash]) Get(ctx context.Context, index TIndex) (res THash, err error) {
-	m.RLock()
-	res, err = m.indexes.Get(ctx, true, index)
-	m.RUnlock()
-	return
+// OpenMountainRange creates a Merkle Mountain Range over a persistent source. When the source
+// implements store.ISizeSource, the size is read from it and kept up to date by Add.
+func OpenMountainRange[TIndex index.Value, THash types.HashType](ctx context.Context, hf types.Hasher[THash], indexes store.IIndexSource[TIndex, THash]) (IMountainRange[TIndex, THash], error) {
+	res := &mmr[TIndex, THash]{
+		indexes: indexes,
+		hf:      hf,
+	}
+	size, err := store.Size(ctx, indexes)
+	if err != nil && !errors.Is(err, types.ErrNotSupported) {
+		return nil, err
+	}
+	res.size = size
+	return res, nil
+}
+
+// Get reads the leaf without the lock. The appends after a Truncate rewrite the leaves above its size,
+// so the leaf is read again if a Truncate runs meanwhile, see readStable.
+func (m *mmr[TIndex, THash]) Get(ctx context.Context, index TIndex) (THash, error) {
+	if index < 0 {
+		var zero THash
+		return zero, types.ErrIndexOutOfRange
+	}
+	return readStable(m, func(TIndex, uint64) (THash, error) {
+		return m.indexes.Get(ctx, true, index)
+	})
 }
 
 func (m *mmr[TIndex, THash]) Add(ctx context.Context, value ...THash) error {
 	m.Lock()
 	defer m.Unlock()
-	for _, v := range value {
-		if err := m.appendMerkle(ctx, v); err != nil {
+	return m.add(ctx, value)
+}
+
+// add appends the values, the caller holds the write lock.
+func (m *mmr[TIndex, THash]) add(ctx context.Context, value []THash) error {
+	if len(value) == 0 {
+		return nil
+	}
+	if maxLeaves := index.MaxLeaves[TIndex](); uint64(len(value)) > uint64(maxLeaves-m.size) {
+		return &CapacityError[TIndex]{Size: m.size, Adding: len(value), MaxLeaves: maxLeaves}
+	}
+	batch := newAppendBatch[TIndex, THash](m.size, len(value))
+	if len(value) >= parallelAppendSize {
+		if err := m.appendParallel(ctx, batch, value); err != nil {
 			return err
 		}
+	} else {
+		for _, v := range value {
+			if err := m.appendMerkle(ctx, batch, v); err != nil {
+				return err
+			}
+		}
+	}
+	if err := m.write(ctx, batch); err != nil {
+		return err
 	}
+	m.updatePeaks(batch)
+	m.size = batch.size
+	m.version++
 	return nil
 }
 
-// getProofIndexes collects the indexes needed to create a proof for the given item.
-func (m *mmr[TIndex, THash]) getProofIndexes(item index.Index[TIndex], maxIndex TIndex) []index.Index[TIndex] {
-	// Initialize the result with the item itself.
-	res := make([]index.Index[TIndex], 0, 10)
-	res = append(res, item)
-	topIndex := item
-	sibIndex := item.GetSibling()
-	if sibIndex != nil && sibIndex.Index() <= maxIndex {
-		topIndex = sibIndex
-		for sibIndex != nil && sibIndex.Index() <= maxIndex {
-			res = append(res, sibIndex)
-			topIndex = sibIndex.Up()
-			sibIndex = topIndex.GetSibling()
+// write sends the batch to the store, the caller holds the write lock.
+func (m *mmr[TIndex, THash]) write(ctx context.Context, batch *appendBatch[TIndex, THash]) error {
+	// The store checks the size in the same transaction, so the writers sharing it can't interleave
+	err := store.SetManyIfSize(ctx, m.indexes, m.size, batch.size, batch.entries)
+	if !errors.Is(err, types.ErrNotSupported) {
+		var conflict *store.SizeConflictError[TIndex]
+		if errors.As(err, &conflict) {
+			m.replaceSize(conflict.Actual)
 		}
+		return err
+	}
+	if err := store.SetMany(ctx, m.indexes, batch.entries); err != nil {
+		return err
+	}
+	// The size is written after the nodes, so a failed write never exposes missing nodes
+	if err := store.SetSize(ctx, m.indexes, batch.size); !errors.Is(err, types.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// AddIfSize adds the values only if the size is still expectedSize, otherwise it returns *store.SizeConflictError
+// with the current size. The size of a persistent source is read again, as other processes may share it.
+func (m *mmr[TIndex, THash]) AddIfSize(ctx context.Context, expectedSize TIndex, values ...THash) error {
+	m.Lock()
+	defer m.Unlock()
+	size, err := store.Size(ctx, m.indexes)
+	if err == nil {
+		m.replaceSize(size)
+	} else if !errors.Is(err, types.ErrNotSupported) {
+		return err
+	}
+	if m.size != expectedSize {
+		return &store.SizeConflictError[TIndex]{Expected: expectedSize, Actual: m.size}
+	}
+	return m.add(ctx, values)
+}
+
+// appendProofKeys appends the keys of the leaf and of its siblings up to the peak of the given height.
+func appendProofKeys[TIndex index.Value](dst []store.Key[TIndex], leaf TIndex, peakHeight int) []store.Key[TIndex] {
+	p := index.LeafPos(leaf)
+	dst = append(dst, posKey(p))
+	for h := 0; h < peakHeight; h++ {
+		dst = append(dst, posKey(p.GetSibling()))
+		p, _ = p.Up()
 	}
-	return res
+	return dst
 }
 
 func (m *mmr[TIndex, THash]) Proof(ctx context.Context, item THash) (*Proof[TIndex, THash], error) {
@@ -78,91 +178,75 @@ func (m *mmr[TIndex, THash]) Proof(ctx context.Context, item THash) (*Proof[TInd
 	return m.ProofByIndex(ctx, leafIndex)
 }
 
+// ProofByIndex creates the proof for the current size. The lock is held only to read the size, so the appends
+// are not blocked while the nodes are read: the appends don't change the nodes below the size. Only the appends
+// after a Truncate rewrite them, the proof is created again if a Truncate runs meanwhile, see readStable.
 func (m *mmr[TIndex, THash]) ProofByIndex(ctx context.Context, i TIndex) (*Proof[TIndex, THash], error) {
-	m.RLock()
-	defer m.RUnlock()
+	return readStable(m, func(size TIndex, _ uint64) (*Proof[TIndex, THash], error) {
+		return m.proofByIndex(ctx, size, i)
+	})
+}
 
-	var err error
+// proofByIndex creates the proof of the leaf for the MMR of the given size.
+func (m *mmr[TIndex, THash]) proofByIndex(ctx context.Context, size, i TIndex) (*Proof[TIndex, THash], error) {
 	proof := &Proof[TIndex, THash]{
 		Target: i,
+		Size:   size,
 		Hashes: []THash{},
 	}
 
-	if i >= m.size {
-		return nil, errors.New("index out of range")
+	if i < 0 || i >= size {
+		return nil, types.ErrIndexOutOfRange
 	}
 
-	peaks := index.GetPeaks[TIndex](index.LeafIndex(m.size - 1))
-	var start TIndex = 0
-	end := m.size
-	targetPeakFound := false
-	var leftPeaks []index.Index[TIndex]
-	var rightPeaks []index.Index[TIndex]
-	var proofIndexes []index.Index[TIndex]
-
-	for _, p := range peaks {
-		if p.IsLeaf() {
-			start = p.Index()
-		} else {
-			start = end - (1 << (p.GetHeight() + 1))
-		}
-
-		if start <= i && i < end {
-			li := index.LeafIndex(i)
-			if m.size == 1 {
-				proofIndexes = []index.Index[TIndex]{li}
-			} else {
-				proofIndexes = m.getProofIndexes(li, end)
-			}
-			targetPeakFound = true
-		} else {
-			if targetPeakFound {
-				leftPeaks = append(leftPeaks, p)
-			} else {
-				rightPeaks = append(rightPeaks, p)
-			}
+	// The keys are collected in a single batch: the left peaks, the right peaks and the path of the leaf
+	var peakBuf, keyBuf [64]store.Key[TIndex]
+	peaks := appendPeakKeys(peakBuf[:0], size)
+	keys := keyBuf[:0]
+	end, target, height := size, 0, 0
+	for k, rest := 0, uint64(size); rest != 0; k, rest = k+1, rest&(rest-1) {
+		h := bits.TrailingZeros64(rest)
+		end -= TIndex(1) << h
+		if end <= i {
+			target, height = k, h
+			break
 		}
-		end = start
 	}
-	proof.LeftPeaks, err = m.indexToHash(ctx, leftPeaks)
-	if err != nil {
-		return nil, err
-	}
-
-	proof.RightPeaks, err = m.indexToHash(ctx, rightPeaks)
-	if err != nil {
-		return nil, err
-	}
-	proof.Hashes, err = m.indexToHash(ctx, proofIndexes)
+	keys = append(append(keys, peaks[target+1:]...), peaks[:target]...)
+	keys = appendProofKeys(keys, i, height)
+	hashes, err := store.GetMany(ctx, m.indexes, keys)
 	if err != nil {
 		return nil, err
 	}
+	left, right := len(peaks)-target-1, target
+	proof.LeftPeaks = hashes[:left:left]
+	proof.RightPeaks = hashes[left : left+right : left+right]
+	proof.Hashes = hashes[left+right:]
 
 	return proof, nil
 }
 
 func (m *mmr[TIndex, THash]) Size() TIndex {
+	m.RLock()
+	defer m.RUnlock()
 	return m.size
 }
 
-func (m *mmr[TIndex, THash]) Root(ctx context.Context) (IRoot[TIndex, THash], error) {
-
+// state returns the size with the epoch it belongs to.
+func (m *mmr[TIndex, THash]) state() (TIndex, uint64) {
 	m.RLock()
 	defer m.RUnlock()
-	peaks := index.GetPeaks(index.LeafIndex(m.size - 1))
-	hashes := make([][]byte, len(peaks))
-	for i, p := range peaks {
-		h, hErr := m.indexes.Get(ctx, p.IsLeaf(), p.Index())
-		if hErr != nil {
-			return nil, hErr
-		}
-		data, hErr := types.HashBytes[THash](h)
-		if hErr != nil {
-			return nil, hErr
-		}
-		hashes[i] = data
-	}
-	r := m.hf(hashes...)
+	return m.size, m.epoch.Load()
+}
 
-	return newRoot[TIndex, THash](r, m.hf), nil
+// Root returns the cached root of the current size, the lock is held only to read the size.
+// The peaks are read again if a Truncate runs meanwhile, see readStable.
+func (m *mmr[TIndex, THash]) Root(ctx context.Context) (IRoot[TIndex, THash], error) {
+	c, err := readStable(m, func(size TIndex, epoch uint64) (*peakCache[TIndex, THash], error) {
+		return m.loadPeaks(ctx, size, epoch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.bagged(m.hf)
 }
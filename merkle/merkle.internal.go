@@ -3,80 +3,133 @@ package merkle
 import (
 	"context"
 	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
 	"github.com/dk-open/go-mmr/types"
+	"slices"
+	"sync"
 )
 
-func (m *mmr[TIndex, THash]) saveLeaf(ctx context.Context, i TIndex, value THash) error {
-	return m.indexes.Set(ctx, true, i, value)
+// appendBatch collects the writes of a single Add, so they are sent to the store in one SetMany.
+type appendBatch[TIndex index.Value, THash types.HashType] struct {
+	size    TIndex
+	entries []store.Entry[TIndex, THash]
+	pending map[store.Key[TIndex]]THash
+	// hashes is the reused slice of the sibling hashes read by appendMerkle.
+	hashes []THash
 }
 
-func (m *mmr[TIndex, THash]) updateNode(ctx context.Context, i index.Index[TIndex], value THash) error {
-	upper := i.RightUp()
-
-	if upper == nil {
-		return nil
-	}
-	upperNode := Node[THash]()
-	if i.IsRight() {
-		upperNode.SetRight(value)
-	} else {
-		upperNode.SetLeft(value)
+func newAppendBatch[TIndex index.Value, THash types.HashType](size TIndex, capacity int) *appendBatch[TIndex, THash] {
+	return &appendBatch[TIndex, THash]{
+		size:    size,
+		entries: make([]store.Entry[TIndex, THash], 0, capacity*2),
+		pending: make(map[store.Key[TIndex]]THash, capacity*2),
 	}
+}
 
-	siblingIndex := i.GetSibling()
-	if sibHash, he := m.indexes.Get(ctx, i.IsLeaf(), siblingIndex.Index()); he == nil {
-		if siblingIndex.IsRight() {
-			upperNode.SetRight(sibHash)
-		} else {
-			upperNode.SetLeft(sibHash)
-		}
-	}
-
-	return buildNodeHash(m.hf, upperNode, func(nodeHash THash) error {
-		if err := m.indexes.Set(ctx, false, upper.Index(), nodeHash); err != nil {
-			return err
-		}
-
-		return m.updateNode(ctx, upper, nodeHash)
-	})
+func (b *appendBatch[TIndex, THash]) set(isLeaf bool, i TIndex, value THash) {
+	key := store.Key[TIndex]{IsLeaf: isLeaf, Index: i}
+	b.entries = append(b.entries, store.Entry[TIndex, THash]{Key: key, Value: value})
+	b.pending[key] = value
+}
 
+// nodeInput is the reusable input of a node hash: the packed children and the argument list of the hasher.
+type nodeInput struct {
+	packed []byte
+	args   [][]byte
 }
 
-func buildNodeHash[THash types.HashType](hf types.Hasher[THash], upperNode INode[THash], f func(THash) error) error {
-	packed, err := upperNode.MarshalBinary()
-	if err != nil {
-		return err
+var nodeInputs = sync.Pool{New: func() any {
+	return &nodeInput{packed: make([]byte, 0, 128), args: make([][]byte, 1)}
+}}
+
+// hashNode hashes the children packed as Node(left, right).MarshalBinary does, reusing the buffers between the calls.
+func hashNode[THash types.HashType](hf types.Hasher[THash], left, right THash) (res THash, err error) {
+	in := nodeInputs.Get().(*nodeInput)
+	defer nodeInputs.Put(in)
+	if in.packed, err = types.AppendHashBytes(in.packed[:0], left); err != nil {
+		return res, err
 	}
-	nodeHash := hf(packed)
-	return f(nodeHash)
+	if in.packed, err = types.AppendHashBytes(in.packed, right); err != nil {
+		return res, err
+	}
+	in.args[0] = in.packed
+	return hf(in.args...), nil
 }
 
-func (m *mmr[TIndex, THash]) appendMerkle(ctx context.Context, value THash) (err error) {
-	nextIndex := m.size
+// appendMerkle adds the leaf to the batch together with the nodes completed by it.
+// Only a right child completes its parent, so the new nodes are on the RightUp chain of the leaf
+// and all their siblings are on the left.
+func (m *mmr[TIndex, THash]) appendMerkle(ctx context.Context, batch *appendBatch[TIndex, THash], value THash) (err error) {
+	leaf := batch.size
+	batch.set(true, leaf, value)
 
-	if err = m.saveLeaf(ctx, nextIndex, value); err != nil {
-		return err
+	// The keys of the chain fit in the arrays on the stack
+	var siblingKeys, upperKeys [64]store.Key[TIndex]
+	siblings, uppers := siblingKeys[:0], upperKeys[:0]
+	p := index.LeafPos(leaf)
+	for up, ok := p.RightUp(); ok; up, ok = up.RightUp() {
+		siblings = append(siblings, posKey(p.GetSibling()))
+		uppers = append(uppers, posKey(up))
+		p = up
 	}
 
-	leafIndex := index.LeafIndex[TIndex](nextIndex)
-	if err = m.updateNode(ctx, leafIndex, value); err != nil {
+	batch.hashes, err = m.getHashes(ctx, batch.pending, siblings, batch.hashes[:0])
+	if err != nil {
 		return err
 	}
+	current := value
+	for i, sibHash := range batch.hashes {
+		if current, err = hashNode(m.hf, sibHash, current); err != nil {
+			return err
+		}
+		batch.set(false, uppers[i].Index, current)
+	}
 
-	m.size = m.size + 1
+	batch.size = batch.size + 1
 	return nil
 }
 
-func (m *mmr[TIndex, THash]) indexToHash(ctx context.Context, indexes []index.Index[TIndex]) ([]THash, error) {
-	res := make([]THash, len(indexes))
-	i := 0
-	for _, nodeIndex := range indexes {
-		if h, err := m.indexes.Get(ctx, nodeIndex.IsLeaf(), nodeIndex.Index()); err != nil {
-			return nil, err
-		} else {
+// getHashes reads the hashes from the pending writes first and the rest with a single GetMany.
+// The hashes are appended to dst, so the caller can reuse the slice.
+func (m *mmr[TIndex, THash]) getHashes(ctx context.Context, pending map[store.Key[TIndex]]THash, keys []store.Key[TIndex], dst []THash) ([]THash, error) {
+	full := slices.Grow(dst, len(keys))[:len(dst)+len(keys)]
+	res := full[len(dst):]
+	var missing []store.Key[TIndex]
+	var missingAt []int
+	for i, key := range keys {
+		if h, ok := pending[key]; ok {
 			res[i] = h
+		} else {
+			missing = append(missing, key)
+			missingAt = append(missingAt, i)
 		}
-		i++
+	}
+
+	hashes, err := store.GetMany(ctx, m.indexes, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, h := range hashes {
+		res[missingAt[i]] = h
+	}
+	return full, nil
+}
+
+func (m *mmr[TIndex, THash]) indexToHash(ctx context.Context, indexes []index.Index[TIndex]) ([]THash, error) {
+	keys := make([]store.Key[TIndex], len(indexes))
+	for i, nodeIndex := range indexes {
+		keys[i] = posKey(index.PosOf(nodeIndex))
+	}
+	res, err := store.GetMany(ctx, m.indexes, keys)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = []THash{}
 	}
 	return res, nil
 }
+
+func posKey[TIndex index.Value](p index.Pos[TIndex]) store.Key[TIndex] {
+	return store.Key[TIndex]{IsLeaf: p.IsLeaf(), Index: p.Index()}
+}
@@ -0,0 +1,36 @@
+package merkle
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/store"
+	"iter"
+)
+
+// ScanLeaves calls fn for the leaves from from to to (exclusive) in ascending order until fn returns false.
+// The range is limited by the size at the time of the call. The appends don't change the leaves below the size,
+// so the lock is not held while the leaves are read and fn may call back into the MMR. Only the appends after
+// a Truncate rewrite them: the scan stops with ErrSnapshotStale if a Truncate runs meanwhile, so fn gets
+// only the leaves read before it.
+func (m *mmr[TIndex, THash]) ScanLeaves(ctx context.Context, from, to TIndex, fn func(i TIndex, leaf THash) bool) error {
+	size, epoch := m.state()
+	stale := false
+	err := store.ScanLeaves(ctx, m.indexes, from, min(to, size), func(i TIndex, leaf THash) bool {
+		if stale = m.checkEpoch(epoch) != nil; stale {
+			return false
+		}
+		return fn(i, leaf)
+	})
+	if (err != nil || stale) && m.checkEpoch(epoch) != nil {
+		return ErrSnapshotStale
+	}
+	return err
+}
+
+// Leaves returns an iterator over the leaves from from to to (exclusive), see ScanLeaves.
+// The iteration stops at the first error, e.g. a cancelled ctx or ErrSnapshotStale, without reporting it.
+// The callers which need to tell an error from the end of the range use ScanLeaves.
+func (m *mmr[TIndex, THash]) Leaves(ctx context.Context, from, to TIndex) iter.Seq2[TIndex, THash] {
+	return func(yield func(TIndex, THash) bool) {
+		_ = m.ScanLeaves(ctx, from, to, yield)
+	}
+}
@@ -0,0 +1,59 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMountainRange_Leaves(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	var hashes []types.Hash256
+	for i := 0; i < 300; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes...))
+
+	next := uint64(5)
+	for i, leaf := range m.Leaves(ctx, 5, 1000) {
+		assert.Equal(t, next, i)
+		assert.Equal(t, hashes[i], leaf)
+		next++
+	}
+	assert.Equal(t, uint64(300), next, "iteration should stop at the size")
+
+	// The lock is not held while iterating, so the range can grow and only the leaves of the captured size are returned
+	var count int
+	for i := range m.Leaves(ctx, 290, 1000) {
+		assert.Less(t, i, uint64(300))
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("more data %d", i)))))
+		count++
+	}
+	assert.Equal(t, 10, count)
+	assert.Equal(t, uint64(310), m.Size())
+
+	for range m.Leaves(ctx, 10, 10) {
+		t.Fatal("empty range should not yield")
+	}
+	var seen []uint64
+	for i := range m.Leaves(ctx, 0, 10) {
+		if seen = append(seen, i); len(seen) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []uint64{0, 1}, seen)
+
+	// The iteration stops on an error, ScanLeaves returns it
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for range m.Leaves(cancelled, 0, 10) {
+		t.Fatal("cancelled iteration should not yield")
+	}
+	assert.ErrorIs(t, m.ScanLeaves(cancelled, 0, 10, func(uint64, types.Hash256) bool { return true }), context.Canceled)
+}
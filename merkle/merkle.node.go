@@ -47,14 +47,18 @@ func (n *node[THash]) SetRight(h THash) {
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (n *node[THash]) MarshalBinary() ([]byte, error) {
-	var buf bytes.Buffer
+	return n.AppendBinary(nil)
+}
+
+// AppendBinary implements the encoding.BinaryAppender interface, it appends the children to b without a buffer.
+func (n *node[THash]) AppendBinary(b []byte) (res []byte, err error) {
+	res = b
 	for _, ch := range n {
-		err := types.BufferWrite(&buf, ch)
-		if err != nil {
+		if res, err = types.AppendHashBytes(res, ch); err != nil {
 			return nil, err
 		}
 	}
-	return buf.Bytes(), nil
+	return res, nil
 }
 
 // UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
@@ -0,0 +1,130 @@
+package merkle
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"runtime"
+	"sync"
+)
+
+const (
+	// parallelAppendSize is the number of values from which Add hashes the nodes in parallel.
+	parallelAppendSize = 1024
+	// parallelChunk is the minimal number of nodes of a level hashed by a worker.
+	parallelChunk = 256
+)
+
+// appendParallel adds the values to the batch with the same result as appendMerkle called for every value.
+// The nodes whose leaves are all in the batch are hashed level by level, the nodes of a level in parallel.
+// Then the few nodes which merge the batch with the previous peaks are hashed on the way up from the first leaf.
+func (m *mmr[TIndex, THash]) appendParallel(ctx context.Context, batch *appendBatch[TIndex, THash], values []THash) error {
+	start, end := batch.size, batch.size+TIndex(len(values))
+	for i, v := range values {
+		batch.set(true, start+TIndex(i), v)
+	}
+
+	// levels[h][k] is the hash of the node of 2^h leaves which starts at the leaf first(h)+k*2^h
+	first := func(h int) TIndex {
+		width := TIndex(1) << h
+		return (start + width - 1) &^ (width - 1)
+	}
+	levels := [][]THash{values}
+	for h := 1; ; h++ {
+		// A level fits only if the batch has 2^h leaves, so first(h) does not overflow TIndex
+		width := TIndex(1) << h
+		if width <= 0 || width > end-start {
+			break
+		}
+		from := first(h)
+		if from+width > end || from+width < from {
+			break
+		}
+		lower, lowerFrom := levels[h-1], first(h-1)
+		offset := int((from - lowerFrom) >> (h - 1))
+		level := make([]THash, int((end-from)>>h))
+		if err := hashLevel(m.hf, level, lower[offset:]); err != nil {
+			return err
+		}
+		for k, nodeHash := range level {
+			batch.set(false, from+TIndex(k)*width+width/2, nodeHash)
+		}
+		levels = append(levels, level)
+	}
+
+	// The nodes which also cover the leaves before the batch, there is at most one of every height
+	for h := 1; ; h++ {
+		width := TIndex(1) << h
+		if width <= 0 {
+			break
+		}
+		from := start &^ (width - 1)
+		if from+width > end || from+width < from {
+			break
+		}
+		if from == start {
+			continue
+		}
+		half := width / 2
+		keys := []store.Key[TIndex]{nodeKey(from, h-1), nodeKey(from+half, h-1)}
+		children, err := m.getHashes(ctx, batch.pending, keys, nil)
+		if err != nil {
+			return err
+		}
+		nodeHash, err := hashNode(m.hf, children[0], children[1])
+		if err != nil {
+			return err
+		}
+		batch.set(false, from+half, nodeHash)
+	}
+	batch.size = end
+	return nil
+}
+
+// nodeKey returns the key of the node of 2^h leaves which starts at the leaf from.
+func nodeKey[TIndex index.Value](from TIndex, h int) store.Key[TIndex] {
+	if h == 0 {
+		return store.Key[TIndex]{IsLeaf: true, Index: from}
+	}
+	return store.Key[TIndex]{Index: from + TIndex(1)<<(h-1)}
+}
+
+// hashLevel hashes the pairs of the lower level into the level, splitting the work between the CPUs.
+func hashLevel[THash types.HashType](hf types.Hasher[THash], level, lower []THash) error {
+	workers := min(runtime.GOMAXPROCS(0), (len(level)+parallelChunk-1)/parallelChunk)
+	if workers <= 1 {
+		return hashPairs(hf, level, lower)
+	}
+	chunk := (len(level) + workers - 1) / workers
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := w*chunk, min((w+1)*chunk, len(level))
+		if from >= to {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[w] = hashPairs(hf, level[from:to], lower[2*from:2*to])
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashPairs[THash types.HashType](hf types.Hasher[THash], level, lower []THash) error {
+	for k := range level {
+		var err error
+		if level[k], err = hashNode(hf, lower[2*k], lower[2*k+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
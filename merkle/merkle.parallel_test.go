@@ -0,0 +1,86 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdd_ParallelBatch(t *testing.T) {
+	ctx := context.Background()
+	for _, before := range []int{0, 1, 5, 1023, 1536} {
+		for _, count := range []int{1024, 1500, 4097} {
+			t.Run(fmt.Sprintf("%d+%d", before, count), func(t *testing.T) {
+				hashes := make([]types.Hash256, before+count)
+				for i := range hashes {
+					hashes[i] = hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+				}
+
+				// Adds of less than 1024 values hash the nodes one leaf at a time
+				sequentialIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+				sequential := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, sequentialIndexes)
+				for i := 0; i < len(hashes); i += 100 {
+					assert.NoError(t, sequential.Add(ctx, hashes[i:min(i+100, len(hashes))]...))
+				}
+
+				parallelIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+				parallel := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, parallelIndexes)
+				assert.NoError(t, parallel.Add(ctx, hashes[:before]...))
+				assert.NoError(t, parallel.Add(ctx, hashes[before:]...))
+				assert.Equal(t, sequential.Size(), parallel.Size())
+
+				for i := uint64(0); i < 2*uint64(len(hashes)); i++ {
+					expected, expectedErr := sequentialIndexes.Get(ctx, false, i)
+					actual, err := parallelIndexes.Get(ctx, false, i)
+					assert.Equal(t, expectedErr, err, "node %d", i)
+					assert.Equal(t, expected, actual, "node %d", i)
+				}
+				for i := uint64(0); i <= uint64(len(hashes)); i++ {
+					expected, expectedErr := sequentialIndexes.Get(ctx, true, i)
+					actual, err := parallelIndexes.Get(ctx, true, i)
+					assert.Equal(t, expectedErr, err, "leaf %d", i)
+					assert.Equal(t, expected, actual, "leaf %d", i)
+				}
+
+				root, err := parallel.Root(ctx)
+				assert.NoError(t, err)
+				expectedRoot, err := sequential.Root(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, expectedRoot.Hash(), root.Hash())
+			})
+		}
+	}
+}
+
+func BenchmarkAdd_LargeBatch(b *testing.B) {
+	ctx := context.Background()
+	hashes := make([]types.Hash256, 100000)
+	for i := range hashes {
+		hashes[i] = hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+	}
+	b.Run("one add", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.DenseIndexSource[uint64, types.Hash256](0))
+			if err := m.Add(ctx, hashes...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("adds of 1000", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.DenseIndexSource[uint64, types.Hash256](0))
+			for i := 0; i < len(hashes); i += 1000 {
+				if err := m.Add(ctx, hashes[i:i+1000]...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
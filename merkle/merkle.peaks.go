@@ -0,0 +1,128 @@
+package merkle
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"math/bits"
+	"sync"
+)
+
+// Peak is a peak of the MMR with its hash.
+type Peak[TIndex index.Value, THash types.HashType] struct {
+	index.Peak[TIndex]
+	Hash THash
+}
+
+// peakCache keeps the peak hashes of a size in the order of index.PeaksForSize,
+// the root is bagged from them on the first use.
+type peakCache[TIndex index.Value, THash types.HashType] struct {
+	epoch  uint64
+	size   TIndex
+	hashes []THash
+	once   sync.Once
+	root   IRoot[TIndex, THash]
+	err    error
+}
+
+func (c *peakCache[TIndex, THash]) bagged(hf types.Hasher[THash]) (IRoot[TIndex, THash], error) {
+	c.once.Do(func() {
+		var r THash
+		if r, c.err = bagPeaks(hf, c.hashes); c.err == nil {
+			c.root = newRoot[TIndex, THash](r, hf)
+		}
+	})
+	return c.root, c.err
+}
+
+// Peaks returns the current peaks in the order of index.GetPeaks, the peak of the latest leaf first.
+func (m *mmr[TIndex, THash]) Peaks(ctx context.Context) ([]Peak[TIndex, THash], error) {
+	c, err := readStable(m, func(size TIndex, epoch uint64) (*peakCache[TIndex, THash], error) {
+		return m.loadPeaks(ctx, size, epoch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.peaks(), nil
+}
+
+func (c *peakCache[TIndex, THash]) peaks() []Peak[TIndex, THash] {
+	layout := index.PeaksForSize(c.size)
+	res := make([]Peak[TIndex, THash], len(layout))
+	for i, p := range layout {
+		res[i] = Peak[TIndex, THash]{Peak: p, Hash: c.hashes[i]}
+	}
+	return res
+}
+
+// loadPeaks returns the peaks of the size from the cache, or reads them from the store.
+// The peaks read for the latest size are cached, so only the first Root after the open or the truncation reads them.
+// The peaks read while a Truncate runs may mix two trees, they are cached under the old epoch only, so they are never
+// used for the new one. The callers outside the lock check the epoch after the call, see readStable.
+func (m *mmr[TIndex, THash]) loadPeaks(ctx context.Context, size TIndex, epoch uint64) (*peakCache[TIndex, THash], error) {
+	cached := m.cache.Load()
+	if cached != nil && cached.epoch == epoch && cached.size == size {
+		return cached, nil
+	}
+	layout := index.PeaksForSize(size)
+	indexes := make([]index.Index[TIndex], len(layout))
+	for i, p := range layout {
+		indexes[i] = p.Index
+	}
+	hashes, err := m.indexToHash(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+	res := &peakCache[TIndex, THash]{epoch: epoch, size: size, hashes: hashes}
+	// An append or a truncation may have replaced the cache meanwhile, a newer cache is kept
+	if cached == nil || cached.epoch < epoch || cached.epoch == epoch && cached.size < size {
+		m.cache.CompareAndSwap(cached, res)
+	}
+	return res, nil
+}
+
+// updatePeaks replaces the cached peaks with the peaks after the batch, the caller holds the write lock.
+// The peaks which are not written by the batch are the previous ones, the cache is dropped if they are not cached.
+// An empty MMR has no peaks, so the cache is kept from the first append on.
+func (m *mmr[TIndex, THash]) updatePeaks(batch *appendBatch[TIndex, THash]) {
+	var previous []THash
+	var previousKeys, keys []store.Key[TIndex]
+	var previousBuf, keysBuf [64]store.Key[TIndex]
+	if m.size > 0 {
+		cached := m.cache.Load()
+		if cached == nil || cached.epoch != m.epoch.Load() || cached.size != m.size {
+			m.cache.Store(nil)
+			return
+		}
+		previous = cached.hashes
+		previousKeys = appendPeakKeys(previousBuf[:0], m.size)
+	}
+	keys = appendPeakKeys(keysBuf[:0], batch.size)
+	hashes := make([]THash, len(keys))
+	for i, key := range keys {
+		if h, ok := batch.pending[key]; ok {
+			hashes[i] = h
+			continue
+		}
+		for j, prev := range previousKeys {
+			if prev == key {
+				hashes[i] = previous[j]
+				break
+			}
+		}
+	}
+	m.cache.Store(&peakCache[TIndex, THash]{epoch: m.epoch.Load(), size: batch.size, hashes: hashes})
+}
+
+// appendPeakKeys appends the keys of the peaks of the size in the order of index.PeaksForSize to dst.
+func appendPeakKeys[TIndex index.Value](dst []store.Key[TIndex], size TIndex) []store.Key[TIndex] {
+	end := size
+	for rest := uint64(size); rest != 0; rest &= rest - 1 {
+		height := bits.TrailingZeros64(rest)
+		start := end - TIndex(1)<<height
+		dst = append(dst, nodeKey(start, height))
+		end = start
+	}
+	return dst
+}
@@ -0,0 +1,120 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMountainRange_Peaks(t *testing.T) {
+	ctx := context.Background()
+	memoryIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memoryIndexes)
+	acc := merkle.NewCompactAccumulator[uint64, types.Hash256](hasher.Sha3_256)
+
+	peaks, err := m.Peaks(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, peaks)
+
+	for i := 0; i < 21; i++ {
+		h := hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+		assert.NoError(t, m.Add(ctx, h))
+		assert.NoError(t, acc.Append(h))
+
+		peaks, err = m.Peaks(ctx)
+		assert.NoError(t, err)
+		hashes := make([]types.Hash256, len(peaks))
+		for j, p := range peaks {
+			hashes[j] = p.Hash
+			stored, gErr := memoryIndexes.Get(ctx, p.Index.IsLeaf(), p.Index.Index())
+			assert.NoError(t, gErr)
+			assert.Equal(t, stored, p.Hash)
+		}
+		assert.Equal(t, acc.Peaks(), hashes)
+	}
+
+	peaks, err = m.Peaks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, peaks, 3)
+	assert.Equal(t, [3]uint64{20, 20, 0}, [3]uint64{peaks[0].FirstLeaf, peaks[0].LastLeaf, uint64(peaks[0].Height)})
+	assert.Equal(t, [3]uint64{16, 19, 2}, [3]uint64{peaks[1].FirstLeaf, peaks[1].LastLeaf, uint64(peaks[1].Height)})
+	assert.Equal(t, [3]uint64{0, 15, 4}, [3]uint64{peaks[2].FirstLeaf, peaks[2].LastLeaf, uint64(peaks[2].Height)})
+}
+
+// sizedIndexSource keeps the size next to the memory source, as the persistent sources do.
+type sizedIndexSource struct {
+	*roundTripIndexSource
+	size uint64
+}
+
+func (s *sizedIndexSource) Size(ctx context.Context) (uint64, error) {
+	return s.size, nil
+}
+
+func (s *sizedIndexSource) SetSize(ctx context.Context, size uint64) error {
+	s.size = size
+	return nil
+}
+
+func TestMountainRange_RootCache(t *testing.T) {
+	ctx := context.Background()
+	source := &sizedIndexSource{roundTripIndexSource: &roundTripIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256]()}}
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+
+	var hashes []types.Hash256
+	for i := 0; i < 30; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, m.Add(ctx, hashes[i]))
+		gets := source.gets
+		root, err := m.Root(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, gets, source.gets, "root should be bagged from the cached peaks")
+		assert.Equal(t, expectedRoot(t, hashes[:i+1]), root.Hash())
+	}
+
+	// The peaks are read once after the reopen and cached again
+	reopened, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	gets := source.gets
+	root, err := reopened.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, hashes[:20]), root.Hash())
+	assert.Equal(t, gets+len(index.PeaksForSize(uint64(20))), source.gets)
+
+	gets = source.gets
+	_, err = reopened.Root(ctx)
+	assert.NoError(t, err)
+	peaks, err := reopened.Peaks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, peaks, 2)
+	assert.Equal(t, gets, source.gets, "second root and peaks should be served from the cache")
+
+	assert.NoError(t, reopened.Add(ctx, hashes[20:]...))
+	gets = source.gets
+	root, err = reopened.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, gets, source.gets)
+	assert.Equal(t, expectedRoot(t, hashes), root.Hash())
+}
+
+// expectedRoot calculates the root of the hashes with the compact accumulator, which never reads a store.
+func expectedRoot(t *testing.T, hashes []types.Hash256) types.Hash256 {
+	return rootOf(t, hashes).Hash()
+}
+
+func rootOf(t *testing.T, hashes []types.Hash256) merkle.IRoot[uint64, types.Hash256] {
+	acc := merkle.NewCompactAccumulator[uint64, types.Hash256](hasher.Sha3_256)
+	assert.NoError(t, acc.Append(hashes...))
+	root, err := acc.Root()
+	assert.NoError(t, err)
+	return root
+}
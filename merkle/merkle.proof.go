@@ -5,9 +5,30 @@ import (
 	"github.com/dk-open/go-mmr/types"
 )
 
+// Proof proves the leaf Target of the MMR of Size leaves. Hashes are the leaf and its siblings up to the peak,
+// RightPeaks and LeftPeaks are the other peaks in the order of index.GetPeaks.
 type Proof[TIndex index.Value, THash types.HashType] struct {
 	Target     TIndex
+	Size       TIndex
 	Hashes     []THash
 	LeftPeaks  []THash
 	RightPeaks []THash
 }
+
+// ConsistencyProof proves that the MMR of OldSize leaves is a prefix of the MMR of NewSize leaves.
+// OldPeaks are the peaks of the old MMR in the order returned by index.GetPeaks, Hashes are the
+// nodes required to rebuild the new peaks from the old ones.
+type ConsistencyProof[TIndex index.Value, THash types.HashType] struct {
+	OldSize  TIndex
+	NewSize  TIndex
+	OldPeaks []THash
+	Hashes   []THash
+}
+
+// AppendProof proves that Leaves were appended to the MMR of OldSize leaves.
+// OldPeaks are the peaks of the old MMR in the order of index.GetPeaks, they are proven by the old root.
+type AppendProof[TIndex index.Value, THash types.HashType] struct {
+	OldSize  TIndex
+	OldPeaks []THash
+	Leaves   []THash
+}
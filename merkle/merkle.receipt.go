@@ -0,0 +1,58 @@
+package merkle
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// Receipt is the result of Append. Indexes are the leaf indexes of the appended values in their order,
+// Size and Root are the state right after the append, Proofs are set by AppendWithProofs only.
+type Receipt[TIndex index.Value, THash types.HashType] struct {
+	Indexes []TIndex
+	Size    TIndex
+	Root    IRoot[TIndex, THash]
+	Proofs  []*Proof[TIndex, THash]
+}
+
+func (m *mmr[TIndex, THash]) Append(ctx context.Context, values ...THash) (*Receipt[TIndex, THash], error) {
+	return m.append(ctx, false, values)
+}
+
+func (m *mmr[TIndex, THash]) AppendWithProofs(ctx context.Context, values ...THash) (*Receipt[TIndex, THash], error) {
+	return m.append(ctx, true, values)
+}
+
+func (m *mmr[TIndex, THash]) append(ctx context.Context, withProofs bool, values []THash) (*Receipt[TIndex, THash], error) {
+	m.Lock()
+	defer m.Unlock()
+
+	start := m.size
+	if err := m.add(ctx, values); err != nil {
+		return nil, err
+	}
+	res := &Receipt[TIndex, THash]{
+		Indexes: make([]TIndex, len(values)),
+		Size:    m.size,
+	}
+	for i := range values {
+		res.Indexes[i] = start + TIndex(i)
+	}
+
+	c, err := m.loadPeaks(ctx, m.size, m.epoch.Load())
+	if err != nil {
+		return nil, err
+	}
+	if res.Root, err = c.bagged(m.hf); err != nil {
+		return nil, err
+	}
+	if withProofs {
+		res.Proofs = make([]*Proof[TIndex, THash], len(values))
+		for i, leaf := range res.Indexes {
+			if res.Proofs[i], err = m.proofByIndex(ctx, m.size, leaf); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
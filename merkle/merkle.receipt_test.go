@@ -0,0 +1,66 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestMountainRange_Append(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+
+	receipt, err := m.Append(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, receipt.Indexes)
+	assert.Equal(t, uint64(0), receipt.Size)
+	assert.Equal(t, hasher.Sha3_256(), receipt.Root.Hash(), "root of the empty range bags no peaks")
+
+	var wg sync.WaitGroup
+	receipts := make([]*merkle.Receipt[uint64, types.Hash256], 8)
+	values := make([][]types.Hash256, len(receipts))
+	for w := range receipts {
+		for i := 0; i <= w; i++ {
+			values[w] = append(values[w], hasher.Sha3_256([]byte(fmt.Sprintf("writer %d value %d", w, i))))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var aErr error
+			if receipts[w], aErr = m.AppendWithProofs(ctx, values[w]...); aErr != nil {
+				t.Errorf("append failed: %v", aErr)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sizes := map[uint64]bool{}
+	for w, r := range receipts {
+		assert.Len(t, r.Indexes, len(values[w]))
+		assert.Len(t, r.Proofs, len(values[w]))
+		assert.Equal(t, r.Indexes[len(r.Indexes)-1]+1, r.Size, "values should be the last leaves of the receipt size")
+		assert.False(t, sizes[r.Size], "every append should see its own size")
+		sizes[r.Size] = true
+		for i, leaf := range r.Indexes {
+			stored, gErr := m.Get(ctx, leaf)
+			assert.NoError(t, gErr)
+			assert.Equal(t, values[w][i], stored)
+			assert.Equal(t, leaf, r.Proofs[i].Target)
+			assert.True(t, r.Root.ValidateProof(r.Proofs[i]), "proof should be valid for the receipt root")
+		}
+	}
+
+	receipt, err = m.Append(ctx, hasher.Sha3_256([]byte("last")))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{36}, receipt.Indexes)
+	assert.Nil(t, receipt.Proofs)
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, root.Hash(), receipt.Root.Hash())
+}
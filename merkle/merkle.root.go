@@ -8,6 +8,8 @@ import (
 type IRoot[TI index.Value, TH types.HashType] interface {
 	Hash() TH
 	ValidateProof(proof *Proof[TI, TH]) bool
+	ValidateConsistency(oldRoot TH, proof *ConsistencyProof[TI, TH]) bool
+	ValidateAppend(oldRoot TH, proof *AppendProof[TI, TH]) bool
 }
 
 type root[TI index.Value, TH types.HashType] struct {
@@ -19,6 +21,11 @@ func newRoot[TI index.Value, TH types.HashType](hash TH, hf types.Hasher[TH]) IR
 	return &root[TI, TH]{hf: hf, hash: hash}
 }
 
+// NewRoot creates a root from a known root hash, e.g. a checkpoint received from a remote log.
+func NewRoot[TI index.Value, TH types.HashType](hf types.Hasher[TH], hash TH) IRoot[TI, TH] {
+	return newRoot[TI, TH](hash, hf)
+}
+
 func (r *root[TI, TH]) Hash() TH {
 	return r.hash
 }
@@ -28,25 +35,9 @@ func (r *root[TI, TH]) ValidateProof(proof *Proof[TI, TH]) bool {
 		return false
 	}
 	hashesToProof := proof.RightPeaks
-
-	currentIndex := index.LeafIndex[TI](proof.Target)
-	currentHash := proof.Hashes[0]
-	for _, siblingHash := range proof.Hashes[1:] {
-		upper := currentIndex.Up()
-		var currentNode INode[TH]
-		if currentIndex.IsRight() {
-			currentNode = Node[TH](siblingHash, currentHash)
-		} else {
-			currentNode = Node[TH](currentHash, siblingHash)
-		}
-
-		if err := buildNodeHash(r.hf, currentNode, func(nodeHash TH) error {
-			currentHash = nodeHash
-			return nil
-		}); err != nil {
-			return false
-		}
-		currentIndex = upper
+	currentHash, err := proofPeak(r.hf, proof)
+	if err != nil {
+		return false
 	}
 	hashesToProof = append(hashesToProof, currentHash)
 
@@ -63,3 +54,69 @@ func (r *root[TI, TH]) ValidateProof(proof *Proof[TI, TH]) bool {
 	calculatedHash := r.hf(hashBytes...)
 	return calculatedHash == r.hash
 }
+
+// ValidateConsistency checks that the MMR with the root oldRoot is a prefix of the MMR with the current root.
+func (r *root[TI, TH]) ValidateConsistency(oldRoot TH, proof *ConsistencyProof[TI, TH]) bool {
+	if proof == nil || proof.OldSize <= 0 || proof.OldSize > proof.NewSize {
+		return false
+	}
+
+	oldPeaks := index.GetPeaks(index.LeafIndex(proof.OldSize - 1))
+	if len(oldPeaks) != len(proof.OldPeaks) {
+		return false
+	}
+	if bagged, err := bagPeaks(r.hf, proof.OldPeaks); err != nil || bagged != oldRoot {
+		return false
+	}
+
+	known := make(map[string]TH, len(oldPeaks))
+	for i, p := range oldPeaks {
+		known[p.Key()] = proof.OldPeaks[i]
+	}
+
+	hashes := proof.Hashes
+	newPeaks := index.GetPeaks(index.LeafIndex(proof.NewSize - 1))
+	peakHashes := make([]TH, 0, len(newPeaks))
+	for _, p := range newPeaks {
+		h, ok := rebuildConsistencyNode(r.hf, p, proof.OldSize, known, &hashes)
+		if !ok {
+			return false
+		}
+		peakHashes = append(peakHashes, h)
+	}
+	if len(hashes) != 0 {
+		return false
+	}
+
+	bagged, err := bagPeaks(r.hf, peakHashes)
+	return err == nil && bagged == r.hash
+}
+
+// proofPeak calculates the hash of the peak from the leaf and the siblings of the proof.
+func proofPeak[TI index.Value, TH types.HashType](hf types.Hasher[TH], proof *Proof[TI, TH]) (TH, error) {
+	current := index.LeafPos(proof.Target)
+	currentHash := proof.Hashes[0]
+	for _, siblingHash := range proof.Hashes[1:] {
+		left, right := currentHash, siblingHash
+		if current.IsRight() {
+			left, right = siblingHash, currentHash
+		}
+		var err error
+		if currentHash, err = hashNode(hf, left, right); err != nil {
+			return currentHash, err
+		}
+		current, _ = current.Up()
+	}
+	return currentHash, nil
+}
+
+// bagPeaks calculates the root hash from the peak hashes ordered as index.GetPeaks returns them.
+func bagPeaks[TH types.HashType](hf types.Hasher[TH], peaks []TH) (res TH, err error) {
+	hashes := make([][]byte, len(peaks))
+	for i, p := range peaks {
+		if hashes[i], err = types.HashBytes[TH](p); err != nil {
+			return res, err
+		}
+	}
+	return hf(hashes...), nil
+}
@@ -0,0 +1,125 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+var ErrSnapshotStale = errors.New("snapshot is stale, the MMR was truncated")
+
+// ISnapshot is an immutable view of the MMR at the size it was taken. Nodes are write-once: the appends
+// only add the nodes above the size, so the snapshot reads stay valid while the MMR keeps growing.
+// Truncate rewrites the nodes, the reads return ErrSnapshotStale after it.
+type ISnapshot[TIndex index.Value, THash types.HashType] interface {
+	// Size is the number of leaves of the snapshot.
+	Size() TIndex
+	// Version is the number of appends made before the snapshot was taken.
+	Version() uint64
+	// Peaks returns the peaks of the snapshot in the order of index.GetPeaks.
+	Peaks() []Peak[TIndex, THash]
+	// Root returns the root of the snapshot, it is bagged from the peaks without reading the store.
+	Root() IRoot[TIndex, THash]
+	// Get returns the leaf, the leaves beyond the snapshot are out of range.
+	Get(ctx context.Context, i TIndex) (THash, error)
+	ProofByIndex(ctx context.Context, i TIndex) (*Proof[TIndex, THash], error)
+	ConsistencyProof(ctx context.Context, oldSize, newSize TIndex) (*ConsistencyProof[TIndex, THash], error)
+	AppendProof(ctx context.Context, oldSize, newSize TIndex) (*AppendProof[TIndex, THash], error)
+}
+
+type snapshot[TIndex index.Value, THash types.HashType] struct {
+	m       *mmr[TIndex, THash]
+	version uint64
+	peaks   *peakCache[TIndex, THash]
+	root    IRoot[TIndex, THash]
+}
+
+// Snapshot holds the read lock only to read the size and the version, the peaks are read after it.
+func (m *mmr[TIndex, THash]) Snapshot(ctx context.Context) (ISnapshot[TIndex, THash], error) {
+	m.RLock()
+	size, epoch, version := m.size, m.epoch.Load(), m.version
+	m.RUnlock()
+
+	peaks, err := m.loadPeaks(ctx, size, epoch)
+	if err != nil {
+		return nil, err
+	}
+	r, err := peaks.bagged(m.hf)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.checkEpoch(epoch); err != nil {
+		return nil, err
+	}
+	return &snapshot[TIndex, THash]{m: m, version: version, peaks: peaks, root: r}, nil
+}
+
+// checkEpoch returns ErrSnapshotStale if the MMR was truncated after the epoch. It is called after the reads,
+// so the nodes rewritten after a truncation are never returned as the nodes of the snapshot. It takes no lock,
+// so the scans may call it for every leaf.
+func (m *mmr[TIndex, THash]) checkEpoch(epoch uint64) error {
+	if m.epoch.Load() != epoch {
+		return ErrSnapshotStale
+	}
+	return nil
+}
+
+func (s *snapshot[TIndex, THash]) Size() TIndex {
+	return s.peaks.size
+}
+
+func (s *snapshot[TIndex, THash]) Version() uint64 {
+	return s.version
+}
+
+func (s *snapshot[TIndex, THash]) Peaks() []Peak[TIndex, THash] {
+	return s.peaks.peaks()
+}
+
+func (s *snapshot[TIndex, THash]) Root() IRoot[TIndex, THash] {
+	return s.root
+}
+
+func (s *snapshot[TIndex, THash]) Get(ctx context.Context, i TIndex) (res THash, err error) {
+	if i < 0 || i >= s.peaks.size {
+		return res, types.ErrIndexOutOfRange
+	}
+	if res, err = s.m.indexes.Get(ctx, true, i); err != nil {
+		return res, err
+	}
+	return res, s.m.checkEpoch(s.peaks.epoch)
+}
+
+func (s *snapshot[TIndex, THash]) ProofByIndex(ctx context.Context, i TIndex) (*Proof[TIndex, THash], error) {
+	res, err := s.m.proofByIndex(ctx, s.peaks.size, i)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.m.checkEpoch(s.peaks.epoch); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *snapshot[TIndex, THash]) ConsistencyProof(ctx context.Context, oldSize, newSize TIndex) (*ConsistencyProof[TIndex, THash], error) {
+	res, err := s.m.consistencyProof(ctx, s.peaks.size, oldSize, newSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.m.checkEpoch(s.peaks.epoch); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *snapshot[TIndex, THash]) AppendProof(ctx context.Context, oldSize, newSize TIndex) (*AppendProof[TIndex, THash], error) {
+	res, err := s.m.appendProof(ctx, s.peaks.size, oldSize, newSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.m.checkEpoch(s.peaks.epoch); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
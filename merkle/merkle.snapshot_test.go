@@ -0,0 +1,129 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// gatedIndexSource blocks the reads of a single leaf until the gate is opened.
+type gatedIndexSource struct {
+	store.IIndexSource[uint64, types.Hash256]
+	leaf    uint64
+	reading chan struct{}
+	gate    chan struct{}
+}
+
+func (s *gatedIndexSource) Get(ctx context.Context, isLeaf bool, i uint64) (types.Hash256, error) {
+	if isLeaf && i == s.leaf {
+		s.reading <- struct{}{}
+		<-s.gate
+	}
+	return s.IIndexSource.Get(ctx, isLeaf, i)
+}
+
+func TestSnapshot_StableWhileAppending(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	var hashes []types.Hash256
+	for i := 0; i < 40; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes[:11]...))
+	expectedRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+	expectedPeaks, err := m.Peaks(ctx)
+	assert.NoError(t, err)
+
+	s, err := m.Snapshot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), s.Size())
+	assert.Equal(t, uint64(1), s.Version())
+
+	for i := 11; i < len(hashes); i += 3 {
+		assert.NoError(t, m.Add(ctx, hashes[i:min(i+3, len(hashes))]...))
+	}
+	assert.Equal(t, expectedRoot.Hash(), s.Root().Hash(), "snapshot root should not change")
+	assert.Equal(t, expectedPeaks, s.Peaks())
+
+	for i := uint64(0); i < s.Size(); i++ {
+		p, err := s.ProofByIndex(ctx, i)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(11), p.Size)
+		assert.True(t, expectedRoot.ValidateProof(p), "proof %d should be valid for the snapshot size", i)
+	}
+	_, err = s.ProofByIndex(ctx, 11)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+	_, err = s.Get(ctx, 11)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+
+	cp, err := s.ConsistencyProof(ctx, 5, 11)
+	assert.NoError(t, err)
+	old := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	assert.NoError(t, old.Add(ctx, hashes[:5]...))
+	oldRoot, err := old.Root(ctx)
+	assert.NoError(t, err)
+	assert.True(t, s.Root().ValidateConsistency(oldRoot.Hash(), cp))
+	_, err = s.ConsistencyProof(ctx, 5, 12)
+	assert.ErrorIs(t, err, types.ErrIndexOutOfRange)
+
+	ap, err := s.AppendProof(ctx, 5, 11)
+	assert.NoError(t, err)
+	assert.True(t, s.Root().ValidateAppend(oldRoot.Hash(), ap))
+
+	latest, err := m.Snapshot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), latest.Size())
+	assert.Equal(t, uint64(11), latest.Version())
+}
+
+func TestSnapshot_ReadsDoNotBlockAppends(t *testing.T) {
+	ctx := context.Background()
+	source := &gatedIndexSource{
+		IIndexSource: store.MemoryIndexSource[uint64, types.Hash256](),
+		leaf:         3,
+		reading:      make(chan struct{}),
+		gate:         make(chan struct{}),
+	}
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, source)
+	assert.NoError(t, m.Add(ctx, types.Hash256{0}, types.Hash256{1}, types.Hash256{2}, types.Hash256{3}))
+
+	s, err := m.Snapshot(ctx)
+	assert.NoError(t, err)
+	proofs := make(chan *merkle.Proof[uint64, types.Hash256])
+	go func() {
+		p, _ := s.ProofByIndex(ctx, 3)
+		proofs <- p
+	}()
+	go func() {
+		p, _ := m.ProofByIndex(ctx, 3)
+		proofs <- p
+	}()
+	<-source.reading
+	<-source.reading
+
+	// Both proofs are blocked in the store, the append must not wait for them
+	added := make(chan error)
+	go func() { added <- m.Add(ctx, types.Hash256{4}, types.Hash256{5}) }()
+	select {
+	case err = <-added:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("append is blocked by the proof reads")
+	}
+
+	close(source.gate)
+	for i := 0; i < 2; i++ {
+		p := <-proofs
+		if assert.NotNil(t, p) {
+			assert.Equal(t, uint64(4), p.Size)
+			assert.True(t, s.Root().ValidateProof(p))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// Truncate removes the leaves from size on with the nodes completed by them. The size is written first,
+// then the nodes are deleted if the source supports it, otherwise the next appends overwrite them.
+// The cached peaks are dropped and the snapshots taken before return ErrSnapshotStale.
+func (m *mmr[TIndex, THash]) Truncate(ctx context.Context, size TIndex) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if size < 0 || size > m.size {
+		return types.ErrIndexOutOfRange
+	}
+	if size == m.size {
+		return nil
+	}
+	if err := store.SetSize(ctx, m.indexes, size); err != nil && !errors.Is(err, types.ErrNotSupported) {
+		return err
+	}
+	removed := m.size
+	m.replaceSize(size)
+
+	if err := store.Delete(ctx, m.indexes, truncatedKeys(size, removed)); err != nil && !errors.Is(err, types.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// replaceSize sets the size which is not the result of an append, the caller holds the write lock.
+// Another process sharing the store may have truncated it, so the epoch is increased and the cached peaks
+// are dropped even if the size grew.
+func (m *mmr[TIndex, THash]) replaceSize(size TIndex) {
+	if size == m.size {
+		return
+	}
+	m.size = size
+	m.epoch.Add(1)
+	m.cache.Store(nil)
+}
+
+// readAttempts is how many times a read outside the lock is repeated when a Truncate runs during it.
+const readAttempts = 3
+
+// readStable calls read with the current size and epoch until the epoch does not change during the call.
+// The reads outside the lock rely on the nodes below the size being written once, which holds only
+// within an epoch: after a Truncate, here or by another writer of the store, the appends rewrite the nodes
+// above the new size, so a read which overlaps them may mix the nodes of the two trees.
+// ErrSnapshotStale is returned if the epoch changes during every attempt.
+func readStable[TIndex index.Value, THash types.HashType, T any](m *mmr[TIndex, THash], read func(size TIndex, epoch uint64) (T, error)) (res T, err error) {
+	for range readAttempts {
+		size, epoch := m.state()
+		res, err = read(size, epoch)
+		if m.checkEpoch(epoch) == nil {
+			return res, err
+		}
+	}
+	var zero T
+	return zero, ErrSnapshotStale
+}
+
+// truncatedKeys returns the leaves from size to oldSize and the nodes completed by them, see appendMerkle.
+func truncatedKeys[TIndex index.Value](size, oldSize TIndex) []store.Key[TIndex] {
+	var res []store.Key[TIndex]
+	for i := size; i < oldSize; i++ {
+		res = append(res, store.Key[TIndex]{IsLeaf: true, Index: i})
+		for upper, ok := index.LeafPos(i).RightUp(); ok; upper, ok = upper.RightUp() {
+			res = append(res, posKey(upper))
+		}
+	}
+	return res
+}
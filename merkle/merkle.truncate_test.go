@@ -0,0 +1,272 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sync"
+	"testing"
+)
+
+// pausedIndexSource pauses after the first read of the leaf until resume is closed, it keeps the size as the persistent sources do.
+type pausedIndexSource struct {
+	store.IIndexSource[uint64, types.Hash256]
+	size   uint64
+	leaf   uint64
+	once   sync.Once
+	paused chan struct{}
+	resume chan struct{}
+}
+
+// openPaused opens the MMR of 7 leaves over the source which pauses the read of the leaf 6, the peaks are not cached yet.
+func openPaused(t *testing.T) (merkle.IMountainRange[uint64, types.Hash256], *pausedIndexSource) {
+	ctx := context.Background()
+	memory := store.MemoryIndexSource[uint64, types.Hash256]()
+	filled := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memory)
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, filled.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	source := &pausedIndexSource{IIndexSource: memory, size: 7, leaf: 6, paused: make(chan struct{}), resume: make(chan struct{})}
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	return m, source
+}
+
+func (s *pausedIndexSource) Get(ctx context.Context, isLeaf bool, i uint64) (types.Hash256, error) {
+	res, err := s.IIndexSource.Get(ctx, isLeaf, i)
+	if isLeaf && i == s.leaf {
+		s.once.Do(func() {
+			close(s.paused)
+			<-s.resume
+		})
+	}
+	return res, err
+}
+
+func (s *pausedIndexSource) Size(ctx context.Context) (uint64, error) {
+	return s.size, nil
+}
+
+func (s *pausedIndexSource) SetSize(ctx context.Context, size uint64) error {
+	s.size = size
+	return nil
+}
+
+// truncateWhilePaused truncates the MMR to 5 leaves and appends 2 other leaves while the read of the leaf 6 is paused.
+// It returns the leaves of the new tree.
+func truncateWhilePaused(t *testing.T, m merkle.IMountainRange[uint64, types.Hash256], source *pausedIndexSource) []types.Hash256 {
+	ctx := context.Background()
+	<-source.paused
+	assert.NoError(t, m.Truncate(ctx, 5))
+	assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte("other data 5")), hasher.Sha3_256([]byte("other data 6"))))
+	close(source.resume)
+
+	var res []types.Hash256
+	for i := uint64(0); i < 7; i++ {
+		leaf, err := m.Get(ctx, i)
+		assert.NoError(t, err)
+		res = append(res, leaf)
+	}
+	return res
+}
+
+func TestMountainRange_RootDuringTruncate(t *testing.T) {
+	ctx := context.Background()
+	m, source := openPaused(t)
+
+	done := make(chan types.Hash256)
+	go func() {
+		root, err := m.Root(ctx)
+		assert.NoError(t, err)
+		done <- root.Hash()
+	}()
+	leaves := truncateWhilePaused(t, m, source)
+	assert.Equal(t, expectedRoot(t, leaves), <-done, "root should not mix the peaks of the truncated tree with the new ones")
+}
+
+func TestMountainRange_Truncate(t *testing.T) {
+	ctx := context.Background()
+	source := &sizedIndexSource{roundTripIndexSource: &roundTripIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256]()}}
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+
+	var hashes, others []types.Hash256
+	for i := 0; i < 20; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+		others = append(others, hasher.Sha3_256([]byte(fmt.Sprintf("other data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes...))
+	s, err := m.Snapshot(ctx)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, m.Truncate(ctx, 21), types.ErrIndexOutOfRange)
+	assert.NoError(t, m.Truncate(ctx, 13))
+	assert.Equal(t, uint64(13), m.Size())
+	assert.Equal(t, uint64(13), source.size, "truncated size should be written to the source")
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, hashes[:13]), root.Hash())
+
+	_, err = s.ProofByIndex(ctx, 2)
+	assert.ErrorIs(t, err, merkle.ErrSnapshotStale)
+	_, err = s.Get(ctx, 2)
+	assert.ErrorIs(t, err, merkle.ErrSnapshotStale)
+	assert.Equal(t, expectedRoot(t, hashes), s.Root().Hash(), "captured root should not change")
+
+	// The appends after the truncation rewrite the removed nodes
+	expected := append(append([]types.Hash256{}, hashes[:13]...), others...)
+	assert.NoError(t, m.Add(ctx, others...))
+	root, err = m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, expected), root.Hash())
+	for i := range expected {
+		p, err := m.ProofByIndex(ctx, uint64(i))
+		assert.NoError(t, err)
+		assert.True(t, root.ValidateProof(p), "proof %d should be valid", i)
+	}
+
+	reopened, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	root, err = reopened.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, expected), root.Hash())
+
+	assert.NoError(t, m.Truncate(ctx, 0))
+	root, err = m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, nil), root.Hash())
+}
+
+func TestMountainRange_TruncateWithoutDelete(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, &roundTripIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256]()})
+	var hashes []types.Hash256
+	for i := 0; i < 9; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes...))
+	assert.NoError(t, m.Truncate(ctx, 3), "source without Delete keeps the nodes for the next appends to overwrite")
+	assert.NoError(t, m.Add(ctx, hashes[5:]...))
+
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, append(append([]types.Hash256{}, hashes[:3]...), hashes[5:]...)), root.Hash())
+}
+
+func TestMountainRange_TruncateDeletes(t *testing.T) {
+	ctx := context.Background()
+	source := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, source)
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))))
+	}
+	assert.NoError(t, m.Truncate(ctx, 5))
+
+	_, err := source.Get(ctx, true, 5)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "truncated leaves should be deleted")
+	for _, node := range []uint64{4, 5, 7} {
+		_, err = source.Get(ctx, false, node)
+		assert.ErrorIs(t, err, types.ErrKeyNotFound, "node %d completed by the removed leaves should be deleted", node)
+	}
+	for _, node := range []uint64{1, 2, 3} {
+		_, err = source.Get(ctx, false, node)
+		assert.NoError(t, err, "node %d of the kept leaves should stay", node)
+	}
+}
+
+func TestMountainRange_ReadsDuringTruncate(t *testing.T) {
+	ctx := context.Background()
+	read := func(name string, call func(m merkle.IMountainRange[uint64, types.Hash256]) error, check func(leaves []types.Hash256)) {
+		m, source := openPaused(t)
+		done := make(chan error)
+		go func() {
+			done <- call(m)
+		}()
+		leaves := truncateWhilePaused(t, m, source)
+		if assert.NoError(t, <-done, name) {
+			check(leaves)
+		}
+	}
+
+	var leaf types.Hash256
+	read("Get", func(m merkle.IMountainRange[uint64, types.Hash256]) (err error) {
+		leaf, err = m.Get(ctx, 6)
+		return err
+	}, func(leaves []types.Hash256) {
+		assert.Equal(t, leaves[6], leaf, "leaf should be the one of the new tree")
+	})
+
+	var proof *merkle.Proof[uint64, types.Hash256]
+	read("ProofByIndex", func(m merkle.IMountainRange[uint64, types.Hash256]) (err error) {
+		proof, err = m.ProofByIndex(ctx, 0)
+		return err
+	}, func(leaves []types.Hash256) {
+		assert.True(t, rootOf(t, leaves).ValidateProof(proof), "proof should be valid for the new tree")
+	})
+
+	var consistency *merkle.ConsistencyProof[uint64, types.Hash256]
+	read("ConsistencyProof", func(m merkle.IMountainRange[uint64, types.Hash256]) (err error) {
+		consistency, err = m.ConsistencyProof(ctx, 3, 7)
+		return err
+	}, func(leaves []types.Hash256) {
+		assert.True(t, rootOf(t, leaves).ValidateConsistency(expectedRoot(t, leaves[:3]), consistency))
+	})
+
+	var appended *merkle.AppendProof[uint64, types.Hash256]
+	read("AppendProof", func(m merkle.IMountainRange[uint64, types.Hash256]) (err error) {
+		appended, err = m.AppendProof(ctx, 5, 7)
+		return err
+	}, func(leaves []types.Hash256) {
+		root, err := merkle.VerifyAppend(hasher.Sha3_256, expectedRoot(t, leaves[:5]), appended)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedRoot(t, leaves), root.Hash())
+	})
+
+	m, source := openPaused(t)
+	done := make(chan error)
+	var scanned int
+	go func() {
+		done <- m.ScanLeaves(ctx, 0, 7, func(i uint64, leaf types.Hash256) bool {
+			scanned++
+			return true
+		})
+	}()
+	truncateWhilePaused(t, m, source)
+	assert.ErrorIs(t, <-done, merkle.ErrSnapshotStale, "scan should not mix the leaves of the two trees")
+	assert.Equal(t, 0, scanned)
+}
+
+func TestMountainRange_TruncatedByAnotherWriter(t *testing.T) {
+	ctx := context.Background()
+	// The leaf is never read, so the source does not pause
+	source := &pausedIndexSource{IIndexSource: store.MemoryIndexSource[uint64, types.Hash256](), leaf: math.MaxUint64}
+	first, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	var leaves []types.Hash256
+	for i := 0; i < 7; i++ {
+		leaves = append(leaves, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, first.Add(ctx, leaves...))
+
+	second, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	snapshot, err := second.Snapshot(ctx)
+	assert.NoError(t, err)
+
+	leaves = append(leaves[:5], hasher.Sha3_256([]byte("other data 5")), hasher.Sha3_256([]byte("other data 6")))
+	assert.NoError(t, first.Truncate(ctx, 5))
+	assert.NoError(t, first.Add(ctx, leaves[5]))
+	// The second writer reads the size of the store, the nodes it saw above it were rewritten
+	assert.NoError(t, second.AddIfSize(ctx, 6, leaves[6]))
+
+	_, err = snapshot.Get(ctx, 5)
+	assert.ErrorIs(t, err, merkle.ErrSnapshotStale, "snapshot should not return the rewritten leaf")
+	root, err := second.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot(t, leaves), root.Hash())
+}
@@ -0,0 +1,136 @@
+package merkle
+
+import (
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"slices"
+)
+
+var (
+	ErrInvalidProof = errors.New("proof is invalid")
+	// ErrTargetPeakMerged is returned by UpdateWithPeaks when the peak of the target was merged into a larger one,
+	// the new siblings are not among the peaks, so the proof has to be updated from the appended leaves.
+	ErrTargetPeakMerged = errors.New("peak of the target was merged")
+)
+
+// IProofUpdater keeps the proofs of the tracked leaves up to date as the MMR grows, without reading from the store.
+type IProofUpdater[TI index.Value, TH types.HashType] interface {
+	// UpdateWithLeaves returns the proof for the MMR with the leaves appended after proof.Size.
+	UpdateWithLeaves(proof *Proof[TI, TH], leaves []TH) (*Proof[TI, TH], error)
+	// UpdateWithPeaks returns the proof for the MMR of newSize leaves with the peaks in the order of index.GetPeaks.
+	UpdateWithPeaks(proof *Proof[TI, TH], newSize TI, peaks []TH) (*Proof[TI, TH], error)
+}
+
+type proofUpdater[TI index.Value, TH types.HashType] struct {
+	hf types.Hasher[TH]
+}
+
+// NewProofUpdater creates a proof updater using the hasher of the MMR.
+func NewProofUpdater[TI index.Value, TH types.HashType](hf types.Hasher[TH]) IProofUpdater[TI, TH] {
+	return &proofUpdater[TI, TH]{hf: hf}
+}
+
+// targetPeak checks the shape of the proof against its size and returns the peak of the target,
+// the peak index and the hash calculated from the siblings.
+func (u *proofUpdater[TI, TH]) targetPeak(proof *Proof[TI, TH]) (index.Index[TI], TH, error) {
+	var peakHash TH
+	if proof == nil || len(proof.Hashes) == 0 || proof.Target < 0 || proof.Target >= proof.Size {
+		return nil, peakHash, ErrInvalidProof
+	}
+	peaks := index.PeaksForSize(proof.Size)
+	if len(peaks) != len(proof.RightPeaks)+1+len(proof.LeftPeaks) {
+		return nil, peakHash, ErrInvalidProof
+	}
+	peak := peaks[len(proof.RightPeaks)]
+	if proof.Target < peak.FirstLeaf || proof.Target > peak.LastLeaf || peak.Height != len(proof.Hashes)-1 {
+		return nil, peakHash, ErrInvalidProof
+	}
+	peakHash, err := proofPeak(u.hf, proof)
+	return peak.Index, peakHash, err
+}
+
+// UpdateWithLeaves appends the leaves to the peaks of the proof. Every merge of the target peak
+// adds the other side of the merge to the siblings of the proof.
+func (u *proofUpdater[TI, TH]) UpdateWithLeaves(proof *Proof[TI, TH], leaves []TH) (*Proof[TI, TH], error) {
+	_, peakHash, err := u.targetPeak(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	// peaks are ordered from the leftmost peak to the latest one, target is the position of the target peak
+	peaks := make([]TH, 0, len(proof.LeftPeaks)+1+len(proof.RightPeaks)+1)
+	peaks = append(peaks, proof.LeftPeaks...)
+	slices.Reverse(peaks)
+	target := len(peaks)
+	peaks = append(peaks, peakHash)
+	for i := len(proof.RightPeaks) - 1; i >= 0; i-- {
+		peaks = append(peaks, proof.RightPeaks[i])
+	}
+
+	hashes := slices.Clone(proof.Hashes)
+	size := proof.Size
+	for _, leaf := range leaves {
+		current, position := leaf, len(peaks)
+		for i, ok := index.LeafPos(size).RightUp(); ok; i, ok = i.RightUp() {
+			sibling, siblingPosition := peaks[len(peaks)-1], len(peaks)-1
+			peaks = peaks[:len(peaks)-1]
+			switch target {
+			case siblingPosition:
+				hashes = append(hashes, current)
+			case position:
+				hashes = append(hashes, sibling)
+				target = siblingPosition
+			}
+			if current, err = hashNode(u.hf, sibling, current); err != nil {
+				return nil, err
+			}
+			position = siblingPosition
+		}
+		peaks = append(peaks, current)
+		size++
+	}
+
+	res := &Proof[TI, TH]{
+		Target:     proof.Target,
+		Size:       size,
+		Hashes:     hashes,
+		LeftPeaks:  slices.Clone(peaks[:target]),
+		RightPeaks: slices.Clone(peaks[target+1:]),
+	}
+	slices.Reverse(res.LeftPeaks)
+	slices.Reverse(res.RightPeaks)
+	return res, nil
+}
+
+// UpdateWithPeaks replaces the peaks of the proof. The left peaks never change, the siblings stay valid
+// only while the target peak is still a peak, otherwise ErrTargetPeakMerged is returned.
+func (u *proofUpdater[TI, TH]) UpdateWithPeaks(proof *Proof[TI, TH], newSize TI, peaks []TH) (*Proof[TI, TH], error) {
+	oldPeak, peakHash, err := u.targetPeak(proof)
+	if err != nil {
+		return nil, err
+	}
+	if newSize < proof.Size {
+		return nil, ErrInvalidProof
+	}
+	newPeaks := index.PeaksForSize(newSize)
+	if len(newPeaks) != len(peaks) {
+		return nil, ErrInvalidProof
+	}
+	position := slices.IndexFunc(newPeaks, func(p index.Peak[TI]) bool {
+		return p.FirstLeaf <= proof.Target && proof.Target <= p.LastLeaf
+	})
+	if newPeaks[position].Index.Key() != oldPeak.Key() {
+		return nil, ErrTargetPeakMerged
+	}
+	if peaks[position] != peakHash || !slices.Equal(peaks[position+1:], proof.LeftPeaks) {
+		return nil, ErrInvalidProof
+	}
+	return &Proof[TI, TH]{
+		Target:     proof.Target,
+		Size:       newSize,
+		Hashes:     slices.Clone(proof.Hashes),
+		LeftPeaks:  slices.Clone(proof.LeftPeaks),
+		RightPeaks: slices.Clone(peaks[:position]),
+	}, nil
+}
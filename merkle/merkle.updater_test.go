@@ -0,0 +1,100 @@
+package merkle_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// historyOfProofs builds the MMR leaf by leaf and keeps the proofs and the peaks of every size.
+func historyOfProofs(t *testing.T, size int) ([]types.Hash256, map[uint64][]*merkle.Proof[uint64, types.Hash256], map[uint64][]types.Hash256) {
+	ctx := context.Background()
+	memoryIndexes := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, memoryIndexes)
+	var leaves []types.Hash256
+	proofs := map[uint64][]*merkle.Proof[uint64, types.Hash256]{}
+	peaks := map[uint64][]types.Hash256{}
+	for i := 0; i < size; i++ {
+		leaves = append(leaves, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+		assert.NoError(t, m.Add(ctx, leaves[i]))
+		for target := uint64(0); target < m.Size(); target++ {
+			proof, err := m.ProofByIndex(ctx, target)
+			assert.NoError(t, err)
+			proofs[m.Size()] = append(proofs[m.Size()], proof)
+		}
+		for _, p := range index.GetPeaks(index.LeafIndex(m.Size() - 1)) {
+			peak, err := memoryIndexes.Get(ctx, p.IsLeaf(), p.Index())
+			assert.NoError(t, err)
+			peaks[m.Size()] = append(peaks[m.Size()], peak)
+		}
+	}
+	return leaves, proofs, peaks
+}
+
+func TestProofUpdater_WithLeaves(t *testing.T) {
+	leaves, proofs, _ := historyOfProofs(t, 26)
+	updater := merkle.NewProofUpdater[uint64, types.Hash256](hasher.Sha3_256)
+	for oldSize := uint64(1); oldSize <= 26; oldSize++ {
+		for newSize := oldSize; newSize <= 26; newSize++ {
+			for target := uint64(0); target < oldSize; target++ {
+				updated, err := updater.UpdateWithLeaves(proofs[oldSize][target], leaves[oldSize:newSize])
+				assert.NoError(t, err)
+				assert.Equal(t, proofs[newSize][target], updated, "leaf %d from %d to %d", target, oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestProofUpdater_WithPeaks(t *testing.T) {
+	_, proofs, peaks := historyOfProofs(t, 20)
+	updater := merkle.NewProofUpdater[uint64, types.Hash256](hasher.Sha3_256)
+	for oldSize := uint64(1); oldSize <= 20; oldSize++ {
+		for newSize := oldSize; newSize <= 20; newSize++ {
+			for target := uint64(0); target < oldSize; target++ {
+				expected := proofs[newSize][target]
+				updated, err := updater.UpdateWithPeaks(proofs[oldSize][target], newSize, peaks[newSize])
+				if len(expected.Hashes) != len(proofs[oldSize][target].Hashes) {
+					assert.ErrorIs(t, err, merkle.ErrTargetPeakMerged, "leaf %d from %d to %d", target, oldSize, newSize)
+					continue
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, expected, updated, "leaf %d from %d to %d", target, oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestProofUpdater_Invalid(t *testing.T) {
+	_, proofs, peaks := historyOfProofs(t, 8)
+	updater := merkle.NewProofUpdater[uint64, types.Hash256](hasher.Sha3_256)
+	proof := proofs[5][4]
+
+	_, err := updater.UpdateWithLeaves(nil, nil)
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof)
+
+	noSize := *proof
+	noSize.Size = 0
+	_, err = updater.UpdateWithLeaves(&noSize, nil)
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof, "proof without the size")
+
+	missingPeak := *proof
+	missingPeak.LeftPeaks = nil
+	_, err = updater.UpdateWithLeaves(&missingPeak, nil)
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof)
+
+	_, err = updater.UpdateWithPeaks(proof, 4, peaks[4])
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof, "MMR can't shrink")
+	_, err = updater.UpdateWithPeaks(proof, 7, peaks[6])
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof, "peaks of another size")
+
+	wrongLeft := append([]types.Hash256{}, peaks[7]...)
+	wrongLeft[len(wrongLeft)-1] = types.Hash256{1}
+	_, err = updater.UpdateWithPeaks(proofs[6][5], 7, wrongLeft)
+	assert.ErrorIs(t, err, merkle.ErrInvalidProof, "left peaks never change")
+}
@@ -84,6 +84,25 @@ func TestMmrProofByIndex(t *testing.T) {
 	assert.True(t, root.ValidateProof(p))
 }
 
+func TestMmrAddIfSize(t *testing.T) {
+	ctx := context.Background()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	h1, h2 := hasher.Sha3_256([]byte("test data 1")), hasher.Sha3_256([]byte("test data 2"))
+
+	assert.NoError(t, m.AddIfSize(ctx, 0, h1))
+	err := m.AddIfSize(ctx, 0, h2)
+	assert.ErrorIs(t, err, types.ErrSizeConflict)
+	var conflict *store.SizeConflictError[uint64]
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, uint64(0), conflict.Expected)
+		assert.Equal(t, uint64(1), conflict.Actual)
+	}
+	assert.Equal(t, uint64(1), m.Size(), "conflicting values should not be added")
+
+	assert.NoError(t, m.AddIfSize(ctx, conflict.Actual, h2))
+	assert.Equal(t, uint64(2), m.Size())
+}
+
 func TestCreateAndValidateProof_DifferentMMRSizes(t *testing.T) {
 	ctx := context.Background()
 
@@ -131,9 +150,9 @@ func TestCreateAndValidateProof_DifferentMMRSizes(t *testing.T) {
 				assert.NoError(t, err, "should add the element without error")
 			}
 
-			// Test Proof Creation for index 0 and last index in MMR (to test edge cases)
+			// Test Proof Creation for every index including the last one (to test edge cases)
 			var i int
-			for i < tc.mmrSize-1 {
+			for i < tc.mmrSize {
 				proof, err := mmr.ProofByIndex(ctx, uint64(i))
 				assert.NoError(t, err, "proof creation should not return an error")
 				assert.NotNil(t, proof, "proof should not be nil")
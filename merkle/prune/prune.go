@@ -0,0 +1,136 @@
+// Package prune removes the leaves which are no longer needed from the MMR storage,
+// while the root and the proofs of the remaining leaves keep working:
+//   - ILeafSet is the bitmap of the live leaves,
+//   - IPruneList keeps the roots of the pruned subtrees, merging the pruned siblings into their parent,
+//   - Compact deletes the leaves and the nodes below the pruned roots from the store.
+//
+// The pruned roots are kept, they are the siblings in the proofs of the remaining leaves.
+// Consistency proofs from the sizes whose peaks were compacted can't be built anymore.
+package prune
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"sync"
+)
+
+var (
+	ErrPruned          = errors.New("leaf is pruned")
+	ErrInvalidEncoding = errors.New("invalid encoding")
+)
+
+type IPruner[TI index.Value, TH types.HashType] interface {
+	// Prune removes the leaves from the leaf set and adds them to the prune list.
+	Prune(ctx context.Context, leaves ...TI) error
+	IsPruned(leaf TI) bool
+	// ProofByIndex returns the proof of the leaf, or ErrPruned if the leaf was pruned.
+	ProofByIndex(ctx context.Context, leaf TI) (*merkle.Proof[TI, TH], error)
+	// Compact deletes the pruned leaves and nodes which are not needed anymore,
+	// it returns types.ErrNotSupported if the store does not implement store.IDeleter.
+	Compact(ctx context.Context) error
+	LeafSet() ILeafSet[TI]
+	PruneList() IPruneList[TI]
+}
+
+type pruner[TI index.Value, TH types.HashType] struct {
+	sync.Mutex
+	m      merkle.IMountainRange[TI, TH]
+	source store.IIndexSource[TI, TH]
+	leafs  ILeafSet[TI]
+	list   IPruneList[TI]
+}
+
+// NewPruner creates a pruner of the mountain range stored in the source. The leaf set and the prune list
+// may be restored with UnmarshalBinary, new empty ones are created when they are nil.
+func NewPruner[TI index.Value, TH types.HashType](m merkle.IMountainRange[TI, TH], source store.IIndexSource[TI, TH], leafs ILeafSet[TI], list IPruneList[TI]) IPruner[TI, TH] {
+	if leafs == nil {
+		leafs = NewLeafSet[TI]()
+	}
+	if list == nil {
+		list = NewPruneList[TI]()
+	}
+	return &pruner[TI, TH]{
+		m:      m,
+		source: source,
+		leafs:  leafs,
+		list:   list,
+	}
+}
+
+func (p *pruner[TI, TH]) Prune(ctx context.Context, leaves ...TI) error {
+	p.Lock()
+	defer p.Unlock()
+	size := p.m.Size()
+	for _, leaf := range leaves {
+		if leaf < 0 || leaf >= size {
+			return types.ErrIndexOutOfRange
+		}
+	}
+	p.leafs.Extend(size)
+	for _, leaf := range leaves {
+		p.leafs.Remove(leaf)
+		p.list.Add(leaf)
+	}
+	return nil
+}
+
+func (p *pruner[TI, TH]) IsPruned(leaf TI) bool {
+	return p.list.IsPruned(true, leaf)
+}
+
+func (p *pruner[TI, TH]) ProofByIndex(ctx context.Context, leaf TI) (*merkle.Proof[TI, TH], error) {
+	if p.list.IsPruned(true, leaf) {
+		return nil, ErrPruned
+	}
+	return p.m.ProofByIndex(ctx, leaf)
+}
+
+func (p *pruner[TI, TH]) Compact(ctx context.Context) error {
+	deleter, ok := p.source.(store.IDeleter[TI])
+	if !ok {
+		return types.ErrNotSupported
+	}
+	p.Lock()
+	defer p.Unlock()
+	return p.list.Compact(ctx, deleter)
+}
+
+func (p *pruner[TI, TH]) LeafSet() ILeafSet[TI] {
+	return p.leafs
+}
+
+func (p *pruner[TI, TH]) PruneList() IPruneList[TI] {
+	return p.list
+}
+
+// decoder reads the uvarints and keeps the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// finish returns the first error or ErrInvalidEncoding if there are bytes left.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		return ErrInvalidEncoding
+	}
+	return d.err
+}
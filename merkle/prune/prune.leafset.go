@@ -0,0 +1,151 @@
+package prune
+
+import (
+	"encoding"
+	"encoding/binary"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ILeafSet is a bitmap of the live leaves, compressed to the runs of the consecutive live leaves.
+type ILeafSet[TI index.Value] interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	// Size returns the number of the leaves covered by the set, live or removed.
+	Size() TI
+	// Count returns the number of the live leaves.
+	Count() TI
+	// Extend adds the leaves from Size up to size as live.
+	Extend(size TI)
+	// Remove removes the leaf, it returns false if the leaf was not live.
+	Remove(leaf TI) bool
+	Contains(leaf TI) bool
+}
+
+// leafRun is the range [start, end) of the live leaves.
+type leafRun[TI index.Value] struct {
+	start TI
+	end   TI
+}
+
+type leafSet[TI index.Value] struct {
+	sync.RWMutex
+	size  TI
+	count TI
+	runs  []leafRun[TI]
+}
+
+// NewLeafSet creates an empty leaf set.
+func NewLeafSet[TI index.Value]() ILeafSet[TI] {
+	return &leafSet[TI]{}
+}
+
+func (s *leafSet[TI]) Size() TI {
+	s.RLock()
+	defer s.RUnlock()
+	return s.size
+}
+
+func (s *leafSet[TI]) Count() TI {
+	s.RLock()
+	defer s.RUnlock()
+	return s.count
+}
+
+func (s *leafSet[TI]) Extend(size TI) {
+	s.Lock()
+	defer s.Unlock()
+	if size <= s.size {
+		return
+	}
+	if last := len(s.runs) - 1; last >= 0 && s.runs[last].end == s.size {
+		s.runs[last].end = size
+	} else {
+		s.runs = append(s.runs, leafRun[TI]{start: s.size, end: size})
+	}
+	s.count += size - s.size
+	s.size = size
+}
+
+func (s *leafSet[TI]) Remove(leaf TI) bool {
+	s.Lock()
+	defer s.Unlock()
+	i, ok := s.find(leaf)
+	if !ok {
+		return false
+	}
+	r := &s.runs[i]
+	switch {
+	case r.start == leaf && r.end == leaf+1:
+		s.runs = slices.Delete(s.runs, i, i+1)
+	case r.start == leaf:
+		r.start++
+	case r.end == leaf+1:
+		r.end--
+	default:
+		tail := leafRun[TI]{start: leaf + 1, end: r.end}
+		r.end = leaf
+		s.runs = slices.Insert(s.runs, i+1, tail)
+	}
+	s.count--
+	return true
+}
+
+func (s *leafSet[TI]) Contains(leaf TI) bool {
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.find(leaf)
+	return ok
+}
+
+// find returns the run which contains the leaf.
+func (s *leafSet[TI]) find(leaf TI) (int, bool) {
+	i := sort.Search(len(s.runs), func(i int) bool { return s.runs[i].end > leaf })
+	return i, i < len(s.runs) && s.runs[i].start <= leaf
+}
+
+// MarshalBinary encodes the size and the runs as uvarints, every run is the gap after the previous run and its length.
+func (s *leafSet[TI]) MarshalBinary() ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	res := binary.AppendUvarint(nil, uint64(s.size))
+	res = binary.AppendUvarint(res, uint64(len(s.runs)))
+	var prev TI
+	for _, r := range s.runs {
+		res = binary.AppendUvarint(res, uint64(r.start-prev))
+		res = binary.AppendUvarint(res, uint64(r.end-r.start))
+		prev = r.end
+	}
+	return res, nil
+}
+
+func (s *leafSet[TI]) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	size := TI(d.uvarint())
+	n := d.uvarint()
+	if n > uint64(len(data)) {
+		return ErrInvalidEncoding
+	}
+	runs := make([]leafRun[TI], 0, n)
+	var prev, count TI
+	for ; n > 0 && d.err == nil; n-- {
+		r := leafRun[TI]{start: prev + TI(d.uvarint())}
+		r.end = r.start + TI(d.uvarint())
+		if r.start < prev || r.end <= r.start || r.end > size {
+			return ErrInvalidEncoding
+		}
+		runs = append(runs, r)
+		count += r.end - r.start
+		prev = r.end
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.size, s.count, s.runs = size, count, runs
+	return nil
+}
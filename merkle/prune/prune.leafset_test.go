@@ -0,0 +1,66 @@
+package prune_test
+
+import (
+	"github.com/dk-open/go-mmr/merkle/prune"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLeafSet(t *testing.T) {
+	s := prune.NewLeafSet[uint32]()
+	s.Extend(10)
+	assert.Equal(t, uint32(10), s.Size())
+	assert.Equal(t, uint32(10), s.Count())
+
+	for _, leaf := range []uint32{0, 9, 5, 4} {
+		assert.True(t, s.Remove(leaf), "leaf %d should be removed", leaf)
+	}
+	assert.False(t, s.Remove(5), "leaf should be removed once")
+	assert.False(t, s.Remove(10), "leaf beyond the size is not live")
+	assert.Equal(t, uint32(6), s.Count())
+
+	s.Extend(12)
+	s.Extend(11)
+	assert.Equal(t, uint32(12), s.Size())
+	var live []uint32
+	for i := uint32(0); i < 13; i++ {
+		if s.Contains(i) {
+			live = append(live, i)
+		}
+	}
+	assert.Equal(t, []uint32{1, 2, 3, 6, 7, 8, 10, 11}, live)
+
+	data, err := s.MarshalBinary()
+	assert.NoError(t, err)
+	restored := prune.NewLeafSet[uint32]()
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, s.Size(), restored.Size())
+	assert.Equal(t, s.Count(), restored.Count())
+	for i := uint32(0); i < 13; i++ {
+		assert.Equal(t, s.Contains(i), restored.Contains(i), "leaf %d", i)
+	}
+}
+
+func TestLeafSet_Compressed(t *testing.T) {
+	s := prune.NewLeafSet[uint64]()
+	s.Extend(1 << 40)
+	s.Remove(1 << 20)
+	data, err := s.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Less(t, len(data), 32, "runs of live leaves should be encoded by their bounds")
+}
+
+func TestLeafSet_InvalidEncoding(t *testing.T) {
+	s := prune.NewLeafSet[uint32]()
+	s.Extend(10)
+	s.Remove(3)
+	data, err := s.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := prune.NewLeafSet[uint32]()
+	assert.ErrorIs(t, restored.UnmarshalBinary(data[:len(data)-1]), prune.ErrInvalidEncoding)
+	assert.ErrorIs(t, restored.UnmarshalBinary(append(data, 0)), prune.ErrInvalidEncoding)
+	assert.ErrorIs(t, restored.UnmarshalBinary([]byte{5, 1, 0, 6}), prune.ErrInvalidEncoding, "run beyond the size")
+	assert.ErrorIs(t, restored.UnmarshalBinary([]byte{5, 1, 0, 0}), prune.ErrInvalidEncoding, "empty run")
+	assert.Equal(t, uint32(0), restored.Size(), "failed decoding should keep the set")
+}
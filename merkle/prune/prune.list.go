@@ -0,0 +1,190 @@
+package prune
+
+import (
+	"context"
+	"encoding"
+	"encoding/binary"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// IPruneList keeps the roots of the pruned subtrees. When both siblings are pruned they are replaced
+// by their parent, so the list stays as small as the number of the pruned ranges.
+// The replaced roots are kept as pending until Compact deletes them from the store.
+type IPruneList[TI index.Value] interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	// Add prunes the leaf, it returns false if the leaf was already pruned.
+	Add(leaf TI) bool
+	// IsPruned returns true if the leaf or the node is a pruned root or lies below one.
+	IsPruned(isLeaf bool, i TI) bool
+	// Roots returns the pruned roots in the order of their leaves.
+	Roots() []store.Key[TI]
+	// Pending returns the leaves and the nodes below the pruned roots which were not deleted yet.
+	Pending() []store.Key[TI]
+	// Compact deletes the pending leaves and nodes from the store.
+	Compact(ctx context.Context, source store.IDeleter[TI]) error
+}
+
+type pruneList[TI index.Value] struct {
+	sync.RWMutex
+	roots   []store.Key[TI]
+	pending []store.Key[TI]
+}
+
+// NewPruneList creates an empty prune list.
+func NewPruneList[TI index.Value]() IPruneList[TI] {
+	return &pruneList[TI]{}
+}
+
+// leafRange returns the first and the last leaf covered by the key.
+func leafRange[TI index.Value](key store.Key[TI]) (first, last TI) {
+	return index.LeafRange(posOf(key))
+}
+
+func posOf[TI index.Value](key store.Key[TI]) index.Pos[TI] {
+	if key.IsLeaf {
+		return index.LeafPos(key.Index)
+	}
+	return index.NodePos(key.Index)
+}
+
+func keyOf[TI index.Value](p index.Pos[TI]) store.Key[TI] {
+	return store.Key[TI]{IsLeaf: p.IsLeaf(), Index: p.Index()}
+}
+
+// covering returns the position of the root which covers the key.
+func (l *pruneList[TI]) covering(key store.Key[TI]) (int, bool) {
+	first, last := leafRange(key)
+	i := sort.Search(len(l.roots), func(i int) bool {
+		rootFirst, _ := leafRange(l.roots[i])
+		return rootFirst > first
+	}) - 1
+	if i < 0 {
+		return i, false
+	}
+	_, rootLast := leafRange(l.roots[i])
+	return i, rootLast >= last
+}
+
+func (l *pruneList[TI]) Add(leaf TI) bool {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.covering(store.Key[TI]{IsLeaf: true, Index: leaf}); ok {
+		return false
+	}
+
+	// A pruned sibling is always a root: a larger root would have covered the leaf as well
+	var current = index.LeafPos(leaf)
+	for {
+		sibling := keyOf(current.GetSibling())
+		i, ok := l.covering(sibling)
+		if !ok || l.roots[i] != sibling {
+			break
+		}
+		l.roots = slices.Delete(l.roots, i, i+1)
+		l.pending = append(l.pending, keyOf(current), sibling)
+		if current, ok = current.Up(); !ok {
+			break
+		}
+	}
+
+	key := keyOf(current)
+	i, _ := l.covering(key)
+	l.roots = slices.Insert(l.roots, i+1, key)
+	return true
+}
+
+func (l *pruneList[TI]) IsPruned(isLeaf bool, i TI) bool {
+	l.RLock()
+	defer l.RUnlock()
+	_, ok := l.covering(store.Key[TI]{IsLeaf: isLeaf, Index: i})
+	return ok
+}
+
+func (l *pruneList[TI]) Roots() []store.Key[TI] {
+	l.RLock()
+	defer l.RUnlock()
+	return slices.Clone(l.roots)
+}
+
+func (l *pruneList[TI]) Pending() []store.Key[TI] {
+	l.RLock()
+	defer l.RUnlock()
+	return slices.Clone(l.pending)
+}
+
+func (l *pruneList[TI]) Compact(ctx context.Context, source store.IDeleter[TI]) error {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.pending) == 0 {
+		return nil
+	}
+	if err := source.Delete(ctx, l.pending); err != nil {
+		return err
+	}
+	l.pending = nil
+	return nil
+}
+
+// MarshalBinary encodes the roots and the pending keys as uvarints of the index and the leaf flag.
+func (l *pruneList[TI]) MarshalBinary() ([]byte, error) {
+	l.RLock()
+	defer l.RUnlock()
+	res := appendKeys(nil, l.roots)
+	return appendKeys(res, l.pending), nil
+}
+
+func (l *pruneList[TI]) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	roots := decodeKeys[TI](&d)
+	pending := decodeKeys[TI](&d)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	var next TI
+	for i, root := range roots {
+		if !root.IsLeaf && root.Index <= 0 {
+			return ErrInvalidEncoding
+		}
+		first, last := leafRange(root)
+		if first < 0 || (i > 0 && first < next) {
+			return ErrInvalidEncoding
+		}
+		next = last + 1
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.roots, l.pending = roots, pending
+	return nil
+}
+
+func appendKeys[TI index.Value](res []byte, keys []store.Key[TI]) []byte {
+	res = binary.AppendUvarint(res, uint64(len(keys)))
+	for _, key := range keys {
+		v := uint64(key.Index) << 1
+		if key.IsLeaf {
+			v |= 1
+		}
+		res = binary.AppendUvarint(res, v)
+	}
+	return res
+}
+
+func decodeKeys[TI index.Value](d *decoder) []store.Key[TI] {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.err = ErrInvalidEncoding
+		return nil
+	}
+	res := make([]store.Key[TI], 0, n)
+	for ; n > 0 && d.err == nil; n-- {
+		v := d.uvarint()
+		res = append(res, store.Key[TI]{IsLeaf: v&1 == 1, Index: TI(v >> 1)})
+	}
+	return res
+}
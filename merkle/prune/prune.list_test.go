@@ -0,0 +1,60 @@
+package prune_test
+
+import (
+	"github.com/dk-open/go-mmr/merkle/prune"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func leaf(i uint32) store.Key[uint32] {
+	return store.Key[uint32]{IsLeaf: true, Index: i}
+}
+
+func node(i uint32) store.Key[uint32] {
+	return store.Key[uint32]{IsLeaf: false, Index: i}
+}
+
+func TestPruneList_MergesSiblings(t *testing.T) {
+	l := prune.NewPruneList[uint32]()
+	assert.True(t, l.Add(1))
+	assert.Equal(t, []store.Key[uint32]{leaf(1)}, l.Roots())
+	assert.Empty(t, l.Pending(), "single leaf is the root of its pruned subtree")
+
+	assert.True(t, l.Add(0))
+	assert.Equal(t, []store.Key[uint32]{node(1)}, l.Roots())
+	assert.Equal(t, []store.Key[uint32]{leaf(0), leaf(1)}, l.Pending())
+	assert.False(t, l.Add(1), "leaf is already pruned")
+
+	assert.True(t, l.Add(6))
+	assert.True(t, l.Add(3))
+	assert.True(t, l.Add(2))
+	assert.Equal(t, []store.Key[uint32]{node(2), leaf(6)}, l.Roots())
+	assert.Equal(t, []store.Key[uint32]{leaf(0), leaf(1), leaf(2), leaf(3), node(3), node(1)}, l.Pending())
+
+	assert.True(t, l.IsPruned(false, 2))
+	assert.True(t, l.IsPruned(false, 3))
+	assert.True(t, l.IsPruned(true, 6))
+	assert.False(t, l.IsPruned(true, 7))
+	assert.False(t, l.IsPruned(false, 5))
+	assert.False(t, l.IsPruned(false, 4))
+
+	data, err := l.MarshalBinary()
+	assert.NoError(t, err)
+	restored := prune.NewPruneList[uint32]()
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, l.Roots(), restored.Roots())
+	assert.Equal(t, l.Pending(), restored.Pending())
+}
+
+func TestPruneList_InvalidEncoding(t *testing.T) {
+	l := prune.NewPruneList[uint32]()
+	l.Add(3)
+	data, err := l.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := prune.NewPruneList[uint32]()
+	assert.ErrorIs(t, restored.UnmarshalBinary(data[:len(data)-1]), prune.ErrInvalidEncoding)
+	assert.ErrorIs(t, restored.UnmarshalBinary([]byte{2, 2, 3, 0}), prune.ErrInvalidEncoding, "node 1 overlaps leaf 1")
+	assert.ErrorIs(t, restored.UnmarshalBinary([]byte{1, 0, 0}), prune.ErrInvalidEncoding, "node 0 does not exist")
+}
@@ -0,0 +1,99 @@
+package prune_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/prune"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// plainIndexSource hides the optional interfaces of the wrapped source.
+type plainIndexSource struct {
+	store.IIndexSource[uint64, types.Hash256]
+}
+
+func leafHash(i int) types.Hash256 {
+	return hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+}
+
+func TestPruner_KeepsRootAndProofs(t *testing.T) {
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(1))
+	for _, size := range []int{1, 2, 7, 16, 33, 100} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			source := store.MemoryIndexSource[uint64, types.Hash256]()
+			m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, source)
+			for i := 0; i < size; i++ {
+				assert.NoError(t, m.Add(ctx, leafHash(i)))
+			}
+			root, err := m.Root(ctx)
+			assert.NoError(t, err)
+
+			p := prune.NewPruner(m, source, nil, nil)
+			pruned := map[uint64]bool{}
+			for i := 0; i < size; i++ {
+				if rnd.Intn(3) > 0 {
+					pruned[uint64(i)] = true
+					assert.NoError(t, p.Prune(ctx, uint64(i)))
+				}
+			}
+			pending := p.PruneList().Pending()
+			assert.NoError(t, p.Compact(ctx))
+			assert.Empty(t, p.PruneList().Pending())
+			for _, key := range pending {
+				_, err = source.Get(ctx, key.IsLeaf, key.Index)
+				assert.ErrorIs(t, err, types.ErrKeyNotFound, "%v should be deleted", key)
+			}
+			assert.Equal(t, uint64(size-len(pruned)), p.LeafSet().Count())
+
+			compacted, err := m.Root(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, root.Hash(), compacted.Hash(), "root should not change")
+			for i := 0; i < size; i++ {
+				proof, pErr := p.ProofByIndex(ctx, uint64(i))
+				if pruned[uint64(i)] {
+					assert.ErrorIs(t, pErr, prune.ErrPruned)
+					continue
+				}
+				assert.NoError(t, pErr, "leaf %d", i)
+				assert.True(t, compacted.ValidateProof(proof), "proof of leaf %d should be valid", i)
+			}
+
+			// The compacted range keeps growing
+			for i := size; i < size+9; i++ {
+				assert.NoError(t, m.Add(ctx, leafHash(i)))
+			}
+			grown, err := m.Root(ctx)
+			assert.NoError(t, err)
+			for i := 0; i < size+9; i++ {
+				if !pruned[uint64(i)] {
+					proof, pErr := p.ProofByIndex(ctx, uint64(i))
+					assert.NoError(t, pErr, "leaf %d", i)
+					assert.True(t, grown.ValidateProof(proof), "proof of leaf %d should be valid after append", i)
+				}
+			}
+		})
+	}
+}
+
+func TestPruner_Errors(t *testing.T) {
+	ctx := context.Background()
+	source := store.MemoryIndexSource[uint64, types.Hash256]()
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, source)
+	assert.NoError(t, m.Add(ctx, leafHash(0), leafHash(1)))
+
+	p := prune.NewPruner(m, source, nil, nil)
+	assert.ErrorIs(t, p.Prune(ctx, 0, 2), types.ErrIndexOutOfRange)
+	assert.False(t, p.IsPruned(0), "nothing should be pruned when a leaf is out of range")
+
+	plain := prune.NewPruner[uint64, types.Hash256](m, &plainIndexSource{source}, nil, nil)
+	assert.NoError(t, plain.Prune(ctx, 0, 1))
+	assert.ErrorIs(t, plain.Compact(ctx), types.ErrNotSupported)
+	assert.Len(t, plain.PruneList().Pending(), 2, "pending keys should be kept for the next compaction")
+}
@@ -134,3 +134,109 @@ func benchmarkMmrWithHasher[TIndex index.Value, THash types.HashType](b *testing
 	}
 	b.ReportAllocs()
 }
+
+func BenchmarkMmrStores(b *testing.B) {
+	ctx := context.Background()
+	numElements := 100000
+
+	b.Run("Benchmark with map store", func(b *testing.B) {
+		benchmarkMmrWithStore[uint64, types.Hash256](b, ctx, hasher.Blake3, store.MemoryIndexSource[uint64, types.Hash256], numElements)
+	})
+
+	b.Run("Benchmark with dense store", func(b *testing.B) {
+		benchmarkMmrWithStore[uint64, types.Hash256](b, ctx, hasher.Blake3, func() store.IIndexSource[uint64, types.Hash256] {
+			return store.DenseIndexSource[uint64, types.Hash256](store.DefaultPageSize)
+		}, numElements)
+	})
+}
+
+func benchmarkMmrWithStore[TIndex index.Value, THash types.HashType](b *testing.B, ctx context.Context, hf func(...[]byte) THash, newStore func() store.IIndexSource[TIndex, THash], numElements int) {
+	hashes := make([]THash, numElements)
+	for i := range hashes {
+		hashes[i] = hf([]byte(fmt.Sprintf("test data %d", i)))
+	}
+
+	// Measure the heap retained by a single filled store
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	indexes := newStore()
+	if err := merkle.NewMountainRange[TIndex, THash](hf, indexes).Add(ctx, hashes...); err != nil {
+		b.Fatalf("failed to add %d hashes: %v", len(hashes), err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	heapPerLeaf := float64(after.HeapAlloc-before.HeapAlloc) / float64(numElements)
+	usage, hasUsage := indexes.(store.IMemoryUsage)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		m := merkle.NewMountainRange[TIndex, THash](hf, newStore())
+		if err := m.Add(ctx, hashes...); err != nil {
+			b.Fatalf("failed to add %d hashes: %v", len(hashes), err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(heapPerLeaf, "heap-B/leaf")
+	if hasUsage {
+		b.ReportMetric(float64(usage.MemoryUsage())/float64(numElements), "store-B/leaf")
+	}
+}
+
+func BenchmarkMmrHotPath(b *testing.B) {
+	ctx := context.Background()
+	numElements := 10000
+	hashes := make([]types.Hash256, numElements)
+	for i := range hashes {
+		hashes[i] = hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i)))
+	}
+	newMmr := func(b *testing.B) merkle.IMountainRange[uint64, types.Hash256] {
+		m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.DenseIndexSource[uint64, types.Hash256](store.DefaultPageSize))
+		if err := m.Add(ctx, hashes...); err != nil {
+			b.Fatalf("failed to add %d hashes: %v", len(hashes), err)
+		}
+		return m
+	}
+
+	b.Run("append", func(b *testing.B) {
+		m := newMmr(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err := m.Add(ctx, hashes[n%numElements]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("root", func(b *testing.B) {
+		m := newMmr(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if _, err := m.Root(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("proof", func(b *testing.B) {
+		m := newMmr(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if _, err := m.ProofByIndex(ctx, uint64(n%numElements)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("hash", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			hasher.Sha3_256(hashes[n%numElements][:], hashes[(n+1)%numElements][:])
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package monitor
+
+import (
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// Checkpoint is a size and root published by a log.
+type Checkpoint[TI index.Value, TH types.HashType] struct {
+	Source string
+	Size   TI
+	Root   TH
+}
+
+// IAlerter receives the problems found by the monitor.
+type IAlerter[TI index.Value, TH types.HashType] interface {
+	// SplitView is called when two checkpoints of the same size have different roots.
+	SplitView(a, b Checkpoint[TI, TH])
+	// Rollback is called when a source reports a smaller size than it reported before.
+	Rollback(prev, cur Checkpoint[TI, TH])
+	// Inconsistent is called when the newer checkpoint can't be proven to extend the older one.
+	Inconsistent(old, cur Checkpoint[TI, TH], err error)
+	// SourceError is called when a source can't be polled.
+	SourceError(source string, err error)
+}
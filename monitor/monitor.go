@@ -0,0 +1,148 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrInconsistent = errors.New("consistency proof is invalid")
+
+type IMonitor interface {
+	// Poll reads every source once and verifies the new checkpoints.
+	Poll(ctx context.Context)
+	// Run polls the sources every interval until the context is done.
+	Run(ctx context.Context) error
+}
+
+type monitor[TI index.Value, TH types.HashType] struct {
+	sync.Mutex
+	hf       types.Hasher[TH]
+	alerter  IAlerter[TI, TH]
+	interval time.Duration
+	sources  map[string]ICheckpointSource[TI, TH]
+	names    []string
+	// latest is the last checkpoint seen from every source.
+	latest map[string]Checkpoint[TI, TH]
+	// roots is the first checkpoint seen for every size from the smallest latest size on, used to detect
+	// split views between the sources. The smaller sizes are dropped, see prune.
+	roots map[TI]Checkpoint[TI, TH]
+}
+
+// NewMonitor creates a monitor which verifies that every checkpoint published by the sources
+// is consistent with every other checkpoint it has seen.
+func NewMonitor[TI index.Value, TH types.HashType](hf types.Hasher[TH], alerter IAlerter[TI, TH], interval time.Duration, sources map[string]ICheckpointSource[TI, TH]) IMonitor {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &monitor[TI, TH]{
+		hf:       hf,
+		alerter:  alerter,
+		interval: interval,
+		sources:  sources,
+		names:    names,
+		latest:   make(map[string]Checkpoint[TI, TH]),
+		roots:    make(map[TI]Checkpoint[TI, TH]),
+	}
+}
+
+func (m *monitor[TI, TH]) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *monitor[TI, TH]) Poll(ctx context.Context) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, name := range m.names {
+		cp, err := m.sources[name].Checkpoint(ctx)
+		if err != nil {
+			m.alerter.SourceError(name, err)
+			continue
+		}
+		if cp.Size == 0 {
+			continue
+		}
+		cp.Source = name
+		m.observe(ctx, cp)
+	}
+	m.prune()
+}
+
+// prune drops the roots of the sizes below the latest size of every source. A source which reports such a size
+// again is rolled back, and its checkpoint is still verified against the latest ones with the consistency proofs.
+func (m *monitor[TI, TH]) prune() {
+	if len(m.latest) == 0 {
+		return
+	}
+	smallest := index.MaxLeaves[TI]()
+	for _, cp := range m.latest {
+		smallest = min(smallest, cp.Size)
+	}
+	for size := range m.roots {
+		if size < smallest {
+			delete(m.roots, size)
+		}
+	}
+}
+
+// observe verifies the new checkpoint against the checkpoints seen before.
+func (m *monitor[TI, TH]) observe(ctx context.Context, cp Checkpoint[TI, TH]) {
+	prev, hasPrev := m.latest[cp.Source]
+	if hasPrev && prev == cp {
+		return
+	}
+
+	if seen, ok := m.roots[cp.Size]; !ok {
+		m.roots[cp.Size] = cp
+	} else if seen.Root != cp.Root {
+		m.alerter.SplitView(seen, cp)
+	}
+
+	if hasPrev && cp.Size < prev.Size {
+		m.alerter.Rollback(prev, cp)
+	}
+
+	for _, name := range m.names {
+		other, ok := m.latest[name]
+		if !ok || other.Size == cp.Size || (name == cp.Source && other.Size > cp.Size) {
+			continue
+		}
+		if other.Size < cp.Size {
+			m.verify(ctx, cp.Source, other, cp)
+		} else {
+			m.verify(ctx, other.Source, cp, other)
+		}
+	}
+	m.latest[cp.Source] = cp
+}
+
+// verify requests a consistency proof from the source and reports an alert if the proof doesn't hold.
+func (m *monitor[TI, TH]) verify(ctx context.Context, source string, old, cur Checkpoint[TI, TH]) {
+	proof, err := m.sources[source].ConsistencyProof(ctx, old.Size, cur.Size)
+	if err != nil {
+		m.alerter.SourceError(source, err)
+		return
+	}
+	if proof.OldSize != old.Size || proof.NewSize != cur.Size ||
+		!merkle.NewRoot[TI, TH](m.hf, cur.Root).ValidateConsistency(old.Root, proof) {
+		m.alerter.Inconsistent(old, cur, ErrInconsistent)
+	}
+}
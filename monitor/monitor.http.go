@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// Handler serves the checkpoints and the consistency proofs of the MMR as JSON for HTTPSource:
+// GET /checkpoint and GET /consistency?old=<size>&new=<size>.
+func Handler[TI index.Value, TH types.HashType](m merkle.IMountainRange[TI, TH]) http.Handler {
+	source := MountainRangeSource(m)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		cp, err := source.Checkpoint(r.Context())
+		writeJSON(w, cp, err)
+	})
+	mux.HandleFunc("GET /consistency", func(w http.ResponseWriter, r *http.Request) {
+		oldSize, oldErr := parseSize[TI](r.URL.Query().Get("old"))
+		newSize, newErr := parseSize[TI](r.URL.Query().Get("new"))
+		if err := errors.Join(oldErr, newErr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		proof, err := source.ConsistencyProof(r.Context(), oldSize, newSize)
+		writeJSON(w, proof, err)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any, err error) {
+	if errors.Is(err, types.ErrIndexOutOfRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// parseSize parses the size of the query, the sizes which don't fit TI are out of range.
+func parseSize[TI index.Value](s string) (TI, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if res := TI(v); res >= 0 && uint64(res) == v {
+		return res, nil
+	}
+	return 0, types.ErrIndexOutOfRange
+}
+
+type httpSource[TI index.Value, TH types.HashType] struct {
+	client *http.Client
+	url    string
+}
+
+// HTTPSource polls the log endpoint at the url served by Handler, http.DefaultClient is used when client is nil.
+func HTTPSource[TI index.Value, TH types.HashType](client *http.Client, url string) ICheckpointSource[TI, TH] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpSource[TI, TH]{client: client, url: strings.TrimSuffix(url, "/")}
+}
+
+func (s *httpSource[TI, TH]) Checkpoint(ctx context.Context) (res Checkpoint[TI, TH], err error) {
+	err = s.get(ctx, "/checkpoint", &res)
+	return res, err
+}
+
+func (s *httpSource[TI, TH]) ConsistencyProof(ctx context.Context, oldSize, newSize TI) (*merkle.ConsistencyProof[TI, TH], error) {
+	res := &merkle.ConsistencyProof[TI, TH]{}
+	if err := s.get(ctx, fmt.Sprintf("/consistency?old=%d&new=%d", oldSize, newSize), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// get decodes the JSON response of the path, the other statuses than 200 return ErrUnexpectedStatus.
+func (s *httpSource[TI, TH]) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w %s from %s: %s", ErrUnexpectedStatus, resp.Status, path, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
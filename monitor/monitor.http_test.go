@@ -0,0 +1,47 @@
+package monitor_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/monitor"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMonitor_HTTPSource(t *testing.T) {
+	ctx := context.Background()
+	a := newLog(t, "leaf", 5)
+	server := httptest.NewServer(monitor.Handler(a))
+	defer server.Close()
+	remote := monitor.HTTPSource[uint64, types.Hash256](nil, server.URL+"/")
+
+	cp, err := remote.Checkpoint(ctx)
+	assert.NoError(t, err)
+	root, err := a.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, checkpoint{Size: 5, Root: root.Hash()}, cp)
+	_, err = remote.ConsistencyProof(ctx, 3, 6)
+	assert.ErrorIs(t, err, monitor.ErrUnexpectedStatus, "sizes beyond the log should be rejected")
+
+	alerts := &recorder{}
+	mon := monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, time.Second, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a": remote,
+		"b": monitor.MountainRangeSource(newLog(t, "leaf", 9)),
+	})
+	mon.Poll(ctx)
+	appendLog(t, a, "leaf", 12)
+	mon.Poll(ctx)
+	assert.True(t, alerts.clean(), "consistent logs should not raise alerts: %+v", alerts)
+
+	fork := httptest.NewServer(monitor.Handler(newLog(t, "fork", 12)))
+	defer fork.Close()
+	mon = monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, time.Second, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a":    remote,
+		"fork": monitor.HTTPSource[uint64, types.Hash256](nil, fork.URL),
+	})
+	mon.Poll(ctx)
+	assert.Len(t, alerts.splitViews, 1)
+}
@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+)
+
+// ICheckpointSource is a log watched by the monitor, a local MMR or a log endpoint served by Handler.
+type ICheckpointSource[TI index.Value, TH types.HashType] interface {
+	// Checkpoint returns the latest size and root published by the log.
+	Checkpoint(ctx context.Context) (Checkpoint[TI, TH], error)
+	// ConsistencyProof returns a proof that the log of oldSize leaves is a prefix of the log of newSize leaves.
+	ConsistencyProof(ctx context.Context, oldSize, newSize TI) (*merkle.ConsistencyProof[TI, TH], error)
+}
+
+type mountainRangeSource[TI index.Value, TH types.HashType] struct {
+	m merkle.IMountainRange[TI, TH]
+}
+
+// MountainRangeSource exposes a local Merkle Mountain Range as a checkpoint source.
+func MountainRangeSource[TI index.Value, TH types.HashType](m merkle.IMountainRange[TI, TH]) ICheckpointSource[TI, TH] {
+	return &mountainRangeSource[TI, TH]{m: m}
+}
+
+// Checkpoint returns the current size and root of the MMR.
+// The root is read again if the MMR has grown between reading the size and the root.
+func (s *mountainRangeSource[TI, TH]) Checkpoint(ctx context.Context) (res Checkpoint[TI, TH], err error) {
+	for {
+		size := s.m.Size()
+		if size == 0 {
+			return res, nil
+		}
+		root, rErr := s.m.Root(ctx)
+		if rErr != nil {
+			return res, rErr
+		}
+		if s.m.Size() == size {
+			res.Size = size
+			res.Root = root.Hash()
+			return res, nil
+		}
+	}
+}
+
+func (s *mountainRangeSource[TI, TH]) ConsistencyProof(ctx context.Context, oldSize, newSize TI) (*merkle.ConsistencyProof[TI, TH], error) {
+	return s.m.ConsistencyProof(ctx, oldSize, newSize)
+}
@@ -0,0 +1,154 @@
+package monitor_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/monitor"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type checkpoint = monitor.Checkpoint[uint64, types.Hash256]
+
+type recorder struct {
+	splitViews   [][2]checkpoint
+	rollbacks    [][2]checkpoint
+	inconsistent [][2]checkpoint
+	errors       []error
+}
+
+func (r *recorder) SplitView(a, b checkpoint) {
+	r.splitViews = append(r.splitViews, [2]checkpoint{a, b})
+}
+
+func (r *recorder) Rollback(prev, cur checkpoint) {
+	r.rollbacks = append(r.rollbacks, [2]checkpoint{prev, cur})
+}
+
+func (r *recorder) Inconsistent(old, cur checkpoint, err error) {
+	r.inconsistent = append(r.inconsistent, [2]checkpoint{old, cur})
+}
+
+func (r *recorder) SourceError(source string, err error) {
+	r.errors = append(r.errors, err)
+}
+
+func (r *recorder) clean() bool {
+	return len(r.splitViews) == 0 && len(r.rollbacks) == 0 && len(r.inconsistent) == 0 && len(r.errors) == 0
+}
+
+// rewindSource reports the checkpoint of the given size instead of the latest one.
+type rewindSource struct {
+	monitor.ICheckpointSource[uint64, types.Hash256]
+	size uint64
+	root types.Hash256
+}
+
+func (s *rewindSource) Checkpoint(ctx context.Context) (checkpoint, error) {
+	if s.size == 0 {
+		return s.ICheckpointSource.Checkpoint(ctx)
+	}
+	return checkpoint{Size: s.size, Root: s.root}, nil
+}
+
+func newLog(t *testing.T, prefix string, size int) merkle.IMountainRange[uint64, types.Hash256] {
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	appendLog(t, m, prefix, size)
+	return m
+}
+
+func appendLog(t *testing.T, m merkle.IMountainRange[uint64, types.Hash256], prefix string, size int) {
+	ctx := context.Background()
+	for i := int(m.Size()); i < size; i++ {
+		assert.NoError(t, m.Add(ctx, hasher.Sha3_256([]byte(fmt.Sprintf("%s %d", prefix, i)))))
+	}
+}
+
+func TestMonitor_ConsistentSources(t *testing.T) {
+	ctx := context.Background()
+	a := newLog(t, "leaf", 5)
+	b := newLog(t, "leaf", 9)
+
+	alerts := &recorder{}
+	mon := monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, time.Second, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a": monitor.MountainRangeSource(a),
+		"b": monitor.MountainRangeSource(b),
+	})
+
+	mon.Poll(ctx)
+	appendLog(t, a, "leaf", 12)
+	mon.Poll(ctx)
+	appendLog(t, b, "leaf", 12)
+	mon.Poll(ctx)
+
+	assert.True(t, alerts.clean(), "consistent logs should not raise alerts: %+v", alerts)
+}
+
+func TestMonitor_SplitView(t *testing.T) {
+	ctx := context.Background()
+	a := newLog(t, "leaf", 7)
+	b := newLog(t, "fork", 7)
+
+	alerts := &recorder{}
+	mon := monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, time.Second, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a": monitor.MountainRangeSource(a),
+		"b": monitor.MountainRangeSource(b),
+	})
+	mon.Poll(ctx)
+
+	if assert.Len(t, alerts.splitViews, 1) {
+		assert.Equal(t, "a", alerts.splitViews[0][0].Source)
+		assert.Equal(t, "b", alerts.splitViews[0][1].Source)
+		assert.Equal(t, uint64(7), alerts.splitViews[0][1].Size)
+	}
+
+	// The fork keeps growing, so it can't be proven to extend the checkpoint of the other log.
+	appendLog(t, b, "fork", 10)
+	mon.Poll(ctx)
+	assert.Len(t, alerts.inconsistent, 1)
+}
+
+func TestMonitor_Rollback(t *testing.T) {
+	ctx := context.Background()
+	a := newLog(t, "leaf", 4)
+	root4, err := a.Root(ctx)
+	assert.NoError(t, err)
+	appendLog(t, a, "leaf", 8)
+
+	src := &rewindSource{ICheckpointSource: monitor.MountainRangeSource(a)}
+	alerts := &recorder{}
+	mon := monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, time.Second, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a": src,
+	})
+	mon.Poll(ctx)
+	assert.True(t, alerts.clean())
+
+	src.size, src.root = 4, root4.Hash()
+	mon.Poll(ctx)
+	if assert.Len(t, alerts.rollbacks, 1) {
+		assert.Equal(t, uint64(8), alerts.rollbacks[0][0].Size)
+		assert.Equal(t, uint64(4), alerts.rollbacks[0][1].Size)
+	}
+	assert.Empty(t, alerts.inconsistent)
+
+	// The same rolled back checkpoint is reported only once.
+	mon.Poll(ctx)
+	assert.Len(t, alerts.rollbacks, 1)
+}
+
+func TestMonitor_Run(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	alerts := &recorder{}
+	mon := monitor.NewMonitor[uint64, types.Hash256](hasher.Sha3_256, alerts, 10*time.Millisecond, map[string]monitor.ICheckpointSource[uint64, types.Hash256]{
+		"a": monitor.MountainRangeSource(newLog(t, "leaf", 3)),
+	})
+	assert.ErrorIs(t, mon.Run(ctx), context.DeadlineExceeded)
+	assert.True(t, alerts.clean())
+}
@@ -0,0 +1,240 @@
+// Package file keeps the MMR in fixed-width record files: leaves.dat, nodes.dat and size.
+// On Linux the records are read from a shared memory mapping, so Get does not copy
+// the records through read syscalls and the readers do not take any lock.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// IFileIndexSource is an index source persisted to the files.
+type IFileIndexSource[K index.Value, V types.HashType] interface {
+	store.IIndexSource[K, V]
+	store.ISizeSource[K]
+	// Sync commits the written records to the disk.
+	Sync() error
+	// Close unmaps and closes the files, the source can't be used after it.
+	Close() error
+}
+
+type indexSource[K index.Value, V types.HashType] struct {
+	// appendMu serializes the conditional writes of the process, the file lock serializes the processes.
+	appendMu sync.Mutex
+	leafs    *table
+	nodes    *table
+	size     *os.File
+}
+
+// Open opens the file source in the directory, creating the files if they do not exist.
+// The hash type must have a fixed width, string hashes are not supported.
+func Open[K index.Value, V types.HashType](dir string) (IFileIndexSource[K, V], error) {
+	var zero V
+	data, err := types.HashBytes(zero)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, types.ErrTypeMismatch
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	res := &indexSource[K, V]{}
+	if res.leafs, err = openTable(filepath.Join(dir, "leaves.dat"), len(data)); err != nil {
+		return nil, err
+	}
+	if res.nodes, err = openTable(filepath.Join(dir, "nodes.dat"), len(data)); err != nil {
+		_ = res.leafs.close()
+		return nil, err
+	}
+	if res.size, err = os.OpenFile(filepath.Join(dir, "size"), os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		_ = res.leafs.close()
+		_ = res.nodes.close()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *indexSource[K, V]) table(isLeaf bool) *table {
+	if isLeaf {
+		return s.leafs
+	}
+	return s.nodes
+}
+
+func (s *indexSource[K, V]) Get(ctx context.Context, isLeaf bool, i K) (res V, err error) {
+	if i < 0 {
+		return res, types.ErrKeyNotFound
+	}
+	data, ok, err := s.table(isLeaf).view(uint64(i))
+	if err != nil {
+		return res, err
+	}
+	if !ok {
+		return res, types.ErrKeyNotFound
+	}
+	return types.BufferRead[V](bytes.NewReader(data))
+}
+
+func (s *indexSource[K, V]) Set(ctx context.Context, isLeaf bool, i K, value V) error {
+	if i < 0 {
+		return types.ErrIndexOutOfRange
+	}
+	data, err := types.HashBytes(value)
+	if err != nil {
+		return err
+	}
+	return s.table(isLeaf).write(uint64(i), data, recordSet)
+}
+
+func (s *indexSource[K, V]) SetMany(ctx context.Context, entries []store.Entry[K, V]) error {
+	for _, e := range entries {
+		if err := s.Set(ctx, e.IsLeaf, e.Index, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete clears the flags of the records, the files never shrink.
+func (s *indexSource[K, V]) Delete(ctx context.Context, keys []store.Key[K]) error {
+	for _, key := range keys {
+		if key.Index < 0 {
+			continue
+		}
+		if err := s.table(key.IsLeaf).clear(uint64(key.Index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanLeaves reads the records without any lock, up to the end of the leaves file.
+func (s *indexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	end := min(uint64(to), s.leafs.count())
+	for i := uint64(max(from, 0)); i < end; i++ {
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		record, ok, err := s.leafs.view(i)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		v, err := types.BufferRead[V](bytes.NewReader(record))
+		if err != nil {
+			return err
+		}
+		if !fn(K(i), v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *indexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	indexes, err := s.leafIndexes(leaf, true)
+	if err != nil {
+		return res, err
+	}
+	return indexes[0], nil
+}
+
+func (s *indexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
+	return s.leafIndexes(leaf, false)
+}
+
+// leafIndexes scans the leaf records, wrap the source with store.LeafIndexed when the lookups by hash are frequent.
+func (s *indexSource[K, V]) leafIndexes(leaf V, first bool) ([]K, error) {
+	data, err := types.HashBytes(leaf)
+	if err != nil {
+		return nil, err
+	}
+	var res []K
+	count := s.leafs.count()
+	for i := uint64(0); i < count; i++ {
+		record, ok, vErr := s.leafs.view(i)
+		if vErr != nil {
+			return nil, vErr
+		}
+		if ok && bytes.Equal(record, data) {
+			if res = append(res, K(i)); first {
+				break
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+func (s *indexSource[K, V]) Size(ctx context.Context) (K, error) {
+	var data [8]byte
+	if _, err := s.size.ReadAt(data[:], 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return K(binary.BigEndian.Uint64(data[:])), nil
+}
+
+func (s *indexSource[K, V]) SetSize(ctx context.Context, size K) error {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], uint64(size))
+	_, err := s.size.WriteAt(data[:], 0)
+	return err
+}
+
+// SetManyIfSize holds the lock of the size file while it checks the size and writes the records,
+// so the processes sharing the directory can't interleave their appends.
+func (s *indexSource[K, V]) SetManyIfSize(ctx context.Context, expected, size K, entries []store.Entry[K, V]) error {
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+	if err := lockFile(s.size); err != nil {
+		return err
+	}
+	defer func() { _ = unlockFile(s.size) }()
+
+	actual, err := s.Size(ctx)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &store.SizeConflictError[K]{Expected: expected, Actual: actual}
+	}
+	if err = s.SetMany(ctx, entries); err != nil {
+		return err
+	}
+	return s.SetSize(ctx, size)
+}
+
+func (s *indexSource[K, V]) Sync() error {
+	if err := s.leafs.sync(); err != nil {
+		return err
+	}
+	if err := s.nodes.sync(); err != nil {
+		return err
+	}
+	return s.size.Sync()
+}
+
+func (s *indexSource[K, V]) Close() error {
+	return errors.Join(s.leafs.close(), s.nodes.close(), s.size.Close())
+}
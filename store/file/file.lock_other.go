@@ -0,0 +1,14 @@
+//go:build !unix
+
+package file
+
+import "os"
+
+// lockFile is a no-op, the appends are serialized within the process only.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapSupported reports whether the reads are served from a memory mapping.
+const mmapSupported = true
+
+// mapFile maps the first size bytes of the file read-only. The mapping is shared, so the records
+// written through the file are visible to the readers without remapping.
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported reports whether the reads are served from a memory mapping.
+const mmapSupported = false
+
+var errMmapNotSupported = errors.New("mmap is not supported")
+
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errMmapNotSupported
+}
+
+func unmapFile(data []byte) error {
+	return errMmapNotSupported
+}
@@ -0,0 +1,188 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// minGrowth is the minimal number of bytes the file grows by.
+	minGrowth = 1 << 20
+
+	recordSet byte = 1
+)
+
+// table is a file of fixed-width records: a flag byte followed by the hash.
+// There is a single writer, the readers are lock-free and read the records from the memory mapping.
+type table struct {
+	mu         sync.Mutex
+	f          *os.File
+	recordSize int64
+	capacity   int64
+	mapping    atomic.Pointer[[]byte]
+	// retired keeps the previous mappings, the lock-free readers may still use them until Close.
+	retired [][]byte
+}
+
+func openTable(path string, width int) (*table, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	t := &table{
+		f:          f,
+		recordSize: int64(width) + 1,
+		capacity:   info.Size(),
+	}
+	if err = t.remap(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// remap maps the whole file, the caller holds the lock.
+func (t *table) remap() error {
+	if !mmapSupported || t.capacity == 0 {
+		return nil
+	}
+	if current := t.mapping.Load(); current != nil && int64(len(*current)) == t.capacity {
+		return nil
+	}
+	data, err := mapFile(t.f, t.capacity)
+	if err != nil {
+		return err
+	}
+	if previous := t.mapping.Swap(&data); previous != nil {
+		t.retired = append(t.retired, *previous)
+	}
+	return nil
+}
+
+// count returns the number of records the file has room for.
+func (t *table) count() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return uint64(t.capacity / t.recordSize)
+}
+
+// view returns the hash bytes of the record. With mmap the result points into the mapping and is valid until Close.
+func (t *table) view(i uint64) ([]byte, bool, error) {
+	offset := int64(i) * t.recordSize
+	if offset < 0 {
+		return nil, false, nil
+	}
+	if !mmapSupported {
+		record := make([]byte, t.recordSize)
+		if n, err := t.f.ReadAt(record, offset); n < len(record) {
+			if err == nil || errors.Is(err, io.EOF) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		return recordHash(record)
+	}
+
+	data := t.mapping.Load()
+	if data == nil || int64(len(*data)) < offset+t.recordSize {
+		// The file may have been grown by the writer of another source
+		if err := t.refresh(); err != nil {
+			return nil, false, err
+		}
+		if data = t.mapping.Load(); data == nil || int64(len(*data)) < offset+t.recordSize {
+			return nil, false, nil
+		}
+	}
+	return recordHash((*data)[offset : offset+t.recordSize])
+}
+
+func recordHash(record []byte) ([]byte, bool, error) {
+	if record[0] != recordSet {
+		return nil, false, nil
+	}
+	return record[1:], true, nil
+}
+
+// refresh remaps the file if it has grown.
+func (t *table) refresh() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > t.capacity {
+		t.capacity = info.Size()
+	}
+	return t.remap()
+}
+
+// write stores the record, growing and remapping the file when needed.
+// The hash is written before the flag, so a reader never sees a flagged record without its hash.
+func (t *table) write(i uint64, hash []byte, flag byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offset := int64(i) * t.recordSize
+	if end := offset + t.recordSize; end > t.capacity {
+		// Another process writing the directory may have grown the file, it must never be truncated
+		info, err := t.f.Stat()
+		if err != nil {
+			return err
+		}
+		t.capacity = max(t.capacity, info.Size())
+	}
+	if end := offset + t.recordSize; end > t.capacity {
+		grown := max(t.capacity*2, end, minGrowth)
+		if err := t.f.Truncate(grown); err != nil {
+			return err
+		}
+		t.capacity = grown
+		if err := t.remap(); err != nil {
+			return err
+		}
+	}
+	if _, err := t.f.WriteAt(hash, offset+1); err != nil {
+		return err
+	}
+	_, err := t.f.WriteAt([]byte{flag}, offset)
+	return err
+}
+
+// clear resets the flag of the record, the records beyond the file are not set anyway.
+func (t *table) clear(i uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offset := int64(i) * t.recordSize
+	if offset+t.recordSize > t.capacity {
+		return nil
+	}
+	_, err := t.f.WriteAt([]byte{0}, offset)
+	return err
+}
+
+func (t *table) sync() error {
+	return t.f.Sync()
+}
+
+func (t *table) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if data := t.mapping.Swap(nil); data != nil {
+		t.retired = append(t.retired, *data)
+	}
+	for _, data := range t.retired {
+		_ = unmapFile(data)
+	}
+	t.retired = nil
+	return t.f.Close()
+}
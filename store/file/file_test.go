@@ -0,0 +1,212 @@
+package file_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/store/file"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func open(t *testing.T, dir string) file.IFileIndexSource[uint64, types.Hash256] {
+	source, err := file.Open[uint64, types.Hash256](dir)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", dir, err)
+	}
+	return source
+}
+
+func TestFileIndexSource_SetAndGet(t *testing.T) {
+	ctx := context.Background()
+	source := open(t, t.TempDir())
+	defer source.Close()
+
+	assert.NoError(t, source.Set(ctx, true, 0, types.Hash256{1}))
+	assert.NoError(t, source.Set(ctx, false, 0, types.Hash256{2}))
+	assert.NoError(t, source.Set(ctx, true, 5, types.Hash256{}))
+
+	res, err := source.Get(ctx, true, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{1}, res)
+	res, err = source.Get(ctx, false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{2}, res)
+	res, err = source.Get(ctx, true, 5)
+	assert.NoError(t, err, "zero hash should be stored")
+	assert.Equal(t, types.Hash256{}, res)
+
+	_, err = source.Get(ctx, true, 4)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "record within the file which was not set")
+	_, err = source.Get(ctx, true, 1<<30)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "record beyond the file")
+
+	leafIndex, err := source.LeafIndex(ctx, types.Hash256{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), leafIndex)
+}
+
+func TestFileIndexSource_Delete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := open(t, dir)
+
+	assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+	assert.NoError(t, source.Set(ctx, false, 1, types.Hash256{2}))
+	assert.NoError(t, store.Delete(ctx, source, []store.Key[uint64]{{IsLeaf: true, Index: 1}, {IsLeaf: true, Index: 1 << 30}}))
+	assert.NoError(t, source.Close())
+
+	source = open(t, dir)
+	defer source.Close()
+	_, err := source.Get(ctx, true, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	res, err := source.Get(ctx, false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{2}, res)
+}
+
+func TestFileIndexSource_ScanLeaves(t *testing.T) {
+	ctx := context.Background()
+	source := open(t, t.TempDir())
+	defer source.Close()
+	for i := uint64(0); i < 20; i++ {
+		if i%5 != 0 {
+			assert.NoError(t, source.Set(ctx, true, i, types.Hash256{byte(i)}))
+		}
+	}
+
+	var indexes []uint64
+	assert.NoError(t, store.ScanLeaves(ctx, source, 3, 1<<40, func(i uint64, leaf types.Hash256) bool {
+		assert.Equal(t, types.Hash256{byte(i)}, leaf)
+		indexes = append(indexes, i)
+		return i < 12
+	}))
+	assert.Equal(t, []uint64{3, 4, 6, 7, 8, 9, 11, 12}, indexes)
+}
+
+func TestFileIndexSource_StringHashes(t *testing.T) {
+	_, err := file.Open[uint64, string](t.TempDir())
+	assert.ErrorIs(t, err, types.ErrTypeMismatch)
+}
+
+func TestFileIndexSource_GrowWithReaders(t *testing.T) {
+	ctx := context.Background()
+	source := open(t, t.TempDir())
+	defer source.Close()
+
+	// 100k records grow the files several times while the readers keep reading the written records
+	const count = 100000
+	var written sync.WaitGroup
+	progress := make(chan uint64, count)
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := range progress {
+				res, err := source.Get(ctx, true, i)
+				if err != nil || res != (types.Hash256{byte(i), byte(i >> 8), byte(i >> 16)}) {
+					t.Errorf("unexpected record %d: %x %v", i, res, err)
+					return
+				}
+			}
+		}()
+	}
+
+	written.Add(1)
+	go func() {
+		defer written.Done()
+		defer close(progress)
+		for i := uint64(0); i < count; i++ {
+			if err := source.Set(ctx, true, i, types.Hash256{byte(i), byte(i >> 8), byte(i >> 16)}); err != nil {
+				t.Errorf("failed to write record %d: %v", i, err)
+				return
+			}
+			progress <- i
+		}
+	}()
+	written.Wait()
+	readers.Wait()
+}
+
+func TestFileIndexSource_ReopenMountainRange(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := open(t, dir)
+
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	var hashes []types.Hash256
+	for i := 0; i < 19; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes[:12]...))
+	assert.NoError(t, source.Sync())
+	assert.NoError(t, source.Close())
+
+	source = open(t, dir)
+	defer source.Close()
+	m, err = merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), m.Size())
+	assert.NoError(t, m.Add(ctx, hashes[12:]...))
+
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	for i := range hashes {
+		p, pErr := m.ProofByIndex(ctx, uint64(i))
+		assert.NoError(t, pErr)
+		assert.True(t, root.ValidateProof(p), "proof %d should be valid", i)
+	}
+}
+
+func TestFileIndexSource_SharedAppends(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Every writer opens its own files, as the separate processes would
+	const writers, appends = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		source := open(t, dir)
+		defer source.Close()
+		m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < appends; i++ {
+				leaf := hasher.Sha3_256([]byte(fmt.Sprintf("writer %d data %d", w, i)))
+				expected := m.Size()
+				for {
+					err := m.AddIfSize(ctx, expected, leaf)
+					var conflict *store.SizeConflictError[uint64]
+					if !errors.As(err, &conflict) {
+						assert.NoError(t, err)
+						break
+					}
+					expected = conflict.Actual
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	source := open(t, dir)
+	defer source.Close()
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(writers*appends), m.Size())
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+	for i := uint64(0); i < m.Size(); i++ {
+		p, pErr := m.ProofByIndex(ctx, i)
+		assert.NoError(t, pErr)
+		assert.True(t, root.ValidateProof(p), "proof %d should be valid", i)
+	}
+}
@@ -0,0 +1,255 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/dk-open/go-mmr/types"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	opPut    byte = 0
+	opDelete byte = 1
+
+	// recordHeaderSize is the payload length and the CRC-32 of the payload.
+	recordHeaderSize = 8
+)
+
+var ErrCorruptedRecord = errors.New("corrupted record")
+
+// IFileKeyValue is a key-value store persisted to a file.
+type IFileKeyValue interface {
+	IKeyValue
+	// Sync commits the written records to the disk.
+	Sync() error
+	Close() error
+}
+
+// logFile is the file of the log, an *os.File outside of the tests.
+type logFile interface {
+	io.ReadWriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+type fileKeyValue struct {
+	sync.RWMutex
+	f    logFile
+	data map[string][]byte
+	// offset is the end of the last complete record, a failed write is truncated back to it.
+	offset int64
+	// err is set when a torn record can't be removed, the batches appended after it would be lost on open.
+	err error
+}
+
+// OpenFile opens the file key-value store, creating the file if it does not exist.
+// The store is an append-only log of batches kept in memory. Every batch is a single record
+// protected by a checksum, a torn record at the end of the file is dropped on open.
+func OpenFile(path string) (IFileKeyValue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	res := &fileKeyValue{
+		f:    f,
+		data: make(map[string][]byte),
+	}
+	if err = res.load(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// load replays the log and truncates the file after the last complete record.
+func (s *fileKeyValue) load() error {
+	log, err := io.ReadAll(s.f)
+	if err != nil {
+		return err
+	}
+
+	var offset int
+	for len(log)-offset >= recordHeaderSize {
+		size := int(binary.BigEndian.Uint32(log[offset:]))
+		checksum := binary.BigEndian.Uint32(log[offset+4:])
+		if len(log)-offset-recordHeaderSize < size {
+			break
+		}
+		payload := log[offset+recordHeaderSize : offset+recordHeaderSize+size]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			break
+		}
+		ops, pErr := decodeOps(payload)
+		if pErr != nil {
+			return pErr
+		}
+		s.apply(ops)
+		offset += recordHeaderSize + size
+	}
+
+	if offset < len(log) {
+		if err = s.f.Truncate(int64(offset)); err != nil {
+			return err
+		}
+	}
+	s.offset = int64(offset)
+	_, err = s.f.Seek(s.offset, io.SeekStart)
+	return err
+}
+
+func (s *fileKeyValue) apply(ops []Op) {
+	for _, op := range ops {
+		if op.Delete {
+			delete(s.data, string(op.Key))
+		} else {
+			s.data[string(op.Key)] = bytes.Clone(op.Value)
+		}
+	}
+}
+
+func (s *fileKeyValue) Get(ctx context.Context, key []byte) ([]byte, error) {
+	s.RLock()
+	v, ok := s.data[string(key)]
+	s.RUnlock()
+	if !ok {
+		return nil, types.ErrKeyNotFound
+	}
+	return bytes.Clone(v), nil
+}
+
+func (s *fileKeyValue) Put(ctx context.Context, key, value []byte) error {
+	return s.Batch(ctx, []Op{{Key: key, Value: value}})
+}
+
+func (s *fileKeyValue) Delete(ctx context.Context, key []byte) error {
+	return s.Batch(ctx, []Op{{Key: key, Delete: true}})
+}
+
+func (s *fileKeyValue) Batch(ctx context.Context, ops []Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	payload := encodeOps(ops)
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := s.f.Write(record); err != nil {
+		// A part of the record may be written, the next records must not follow it
+		if s.err = s.rewind(); s.err != nil {
+			return errors.Join(err, s.err)
+		}
+		return err
+	}
+	s.offset += int64(len(record))
+	s.apply(ops)
+	return nil
+}
+
+// rewind truncates the log to the last complete record.
+func (s *fileKeyValue) rewind() error {
+	if err := s.f.Truncate(s.offset); err != nil {
+		return err
+	}
+	_, err := s.f.Seek(s.offset, io.SeekStart)
+	return err
+}
+
+func (s *fileKeyValue) Iterate(ctx context.Context, prefix []byte, fn func(key, value []byte) bool) error {
+	s.RLock()
+	keys := make([]string, 0)
+	for k := range s.data {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = s.data[k]
+	}
+	s.RUnlock()
+
+	for i, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn([]byte(k), bytes.Clone(values[i])) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *fileKeyValue) Sync() error {
+	return s.f.Sync()
+}
+
+func (s *fileKeyValue) Close() error {
+	return s.f.Close()
+}
+
+func encodeOps(ops []Op) []byte {
+	var res []byte
+	for _, op := range ops {
+		if op.Delete {
+			res = append(res, opDelete)
+			res = binary.AppendUvarint(res, uint64(len(op.Key)))
+			res = append(res, op.Key...)
+			continue
+		}
+		res = append(res, opPut)
+		res = binary.AppendUvarint(res, uint64(len(op.Key)))
+		res = append(res, op.Key...)
+		res = binary.AppendUvarint(res, uint64(len(op.Value)))
+		res = append(res, op.Value...)
+	}
+	return res
+}
+
+func decodeOps(payload []byte) (res []Op, err error) {
+	readBytes := func() ([]byte, error) {
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || uint64(len(payload)-n) < size {
+			return nil, ErrCorruptedRecord
+		}
+		data := payload[n : n+int(size)]
+		payload = payload[n+int(size):]
+		return data, nil
+	}
+
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+		var op Op
+		if op.Key, err = readBytes(); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case opPut:
+			if op.Value, err = readBytes(); err != nil {
+				return nil, err
+			}
+		case opDelete:
+			op.Delete = true
+		default:
+			return nil, ErrCorruptedRecord
+		}
+		res = append(res, op)
+	}
+	return res, nil
+}
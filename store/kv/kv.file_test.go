@@ -0,0 +1,119 @@
+package kv_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/store/kv"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openFile(t *testing.T, path string) kv.IFileKeyValue {
+	db, err := kv.OpenFile(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	return db
+}
+
+func TestFileKeyValue_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+
+	assert.NoError(t, db.Put(ctx, []byte("a"), []byte("1")))
+	v, err := db.Get(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+
+	assert.NoError(t, db.Delete(ctx, []byte("a")))
+	_, err = db.Get(ctx, []byte("a"))
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestFileKeyValue_Iterate(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+
+	assert.NoError(t, db.Batch(ctx, []kv.Op{
+		{Key: []byte("p/c"), Value: []byte("3")},
+		{Key: []byte("p/a"), Value: []byte("1")},
+		{Key: []byte("q/a"), Value: []byte("x")},
+		{Key: []byte("p/b"), Value: []byte("2")},
+	}))
+
+	var keys []string
+	assert.NoError(t, db.Iterate(ctx, []byte("p/"), func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}))
+	assert.Equal(t, []string{"p/a", "p/b", "p/c"}, keys, "keys should be iterated in ascending order")
+
+	keys = nil
+	assert.NoError(t, db.Iterate(ctx, []byte("p/"), func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return false
+	}))
+	assert.Equal(t, []string{"p/a"}, keys, "iteration should stop when fn returns false")
+}
+
+func TestFileKeyValue_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.log")
+
+	db := openFile(t, path)
+	assert.NoError(t, db.Put(ctx, []byte("a"), []byte("1")))
+	assert.NoError(t, db.Batch(ctx, []kv.Op{
+		{Key: []byte("b"), Value: []byte("2")},
+		{Key: []byte("a"), Delete: true},
+	}))
+	assert.NoError(t, db.Sync())
+	assert.NoError(t, db.Close())
+
+	// Simulate a torn write at the end of the log
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	db = openFile(t, path)
+	defer db.Close()
+	_, err = db.Get(ctx, []byte("a"))
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	v, err := db.Get(ctx, []byte("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), v)
+
+	truncated, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), truncated.Size(), "torn record should be truncated")
+
+	assert.NoError(t, db.Put(ctx, []byte("c"), []byte("3")))
+	v, err = db.Get(ctx, []byte("c"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3"), v)
+}
+
+func TestFileKeyValue_Copies(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+	assert.NoError(t, db.Put(ctx, []byte("a"), []byte("1")))
+
+	v, err := db.Get(ctx, []byte("a"))
+	assert.NoError(t, err)
+	v[0] = '2'
+	assert.NoError(t, db.Iterate(ctx, nil, func(key, value []byte) bool {
+		value[0] = '3'
+		return true
+	}))
+	v, err = db.Get(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v, "callers should not change the stored value")
+}
@@ -0,0 +1,48 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+// tornFile writes the first half of the record and fails.
+type tornFile struct {
+	logFile
+}
+
+func (f tornFile) Write(p []byte) (int, error) {
+	n, _ := f.logFile.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func TestFileKeyValue_TornBatch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.log")
+	db, err := OpenFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Put(ctx, []byte("a"), []byte("1")))
+
+	s := db.(*fileKeyValue)
+	f := s.f
+	s.f = tornFile{f}
+	assert.Error(t, db.Put(ctx, []byte("b"), []byte("2")))
+	s.f = f
+	assert.NoError(t, db.Put(ctx, []byte("c"), []byte("3")))
+	assert.NoError(t, db.Close())
+
+	// The batch after the failed one survives the reopen
+	db, err = OpenFile(path)
+	assert.NoError(t, err)
+	defer db.Close()
+	v, err := db.Get(ctx, []byte("c"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3"), v)
+	v, err = db.Get(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+	_, err = db.Get(ctx, []byte("b"))
+	assert.Error(t, err, "failed batch should not be applied")
+}
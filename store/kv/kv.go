@@ -0,0 +1,24 @@
+package kv
+
+import (
+	"context"
+)
+
+// IKeyValue is a minimal byte-oriented key-value store.
+type IKeyValue interface {
+	// Get returns the value of the key or types.ErrKeyNotFound.
+	Get(ctx context.Context, key []byte) ([]byte, error)
+	Put(ctx context.Context, key, value []byte) error
+	Delete(ctx context.Context, key []byte) error
+	// Batch applies all the operations atomically.
+	Batch(ctx context.Context, ops []Op) error
+	// Iterate calls fn for every key with the prefix in ascending order of the keys until fn returns false.
+	Iterate(ctx context.Context, prefix []byte, fn func(key, value []byte) bool) error
+}
+
+// Op is a single operation of a batch, the key is deleted when Delete is set.
+type Op struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
@@ -0,0 +1,174 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+)
+
+const (
+	leafTag byte = 'l'
+	nodeTag byte = 'n'
+	sizeTag byte = 's'
+)
+
+type indexSource[K index.Value, V types.HashType] struct {
+	db        IKeyValue
+	namespace []byte
+}
+
+// IndexSource creates an index source on top of the key-value store.
+// Keys are the namespace, a leaf or node tag and the big-endian index, so the leaves and the nodes
+// are stored in the order of their indexes and several MMRs can share the store under different namespaces.
+// The source keeps the size for merkle.OpenMountainRange, but IKeyValue has no compare-and-swap, so it does not
+// implement store.IConditionalSetter: AddIfSize compares the size read before the write, so the MMR must not be
+// written by several processes sharing the store.
+func IndexSource[K index.Value, V types.HashType](db IKeyValue, namespace []byte) store.IIndexSource[K, V] {
+	return &indexSource[K, V]{
+		db:        db,
+		namespace: bytes.Clone(namespace),
+	}
+}
+
+// prefix returns the prefix of all the leaf or node keys.
+func (s *indexSource[K, V]) prefix(isLeaf bool) []byte {
+	res := make([]byte, len(s.namespace), len(s.namespace)+9)
+	copy(res, s.namespace)
+	if isLeaf {
+		return append(res, leafTag)
+	}
+	return append(res, nodeTag)
+}
+
+func (s *indexSource[K, V]) key(isLeaf bool, i K) []byte {
+	return binary.BigEndian.AppendUint64(s.prefix(isLeaf), encodeIndex(i))
+}
+
+func (s *indexSource[K, V]) Get(ctx context.Context, isLeaf bool, i K) (res V, err error) {
+	data, err := s.db.Get(ctx, s.key(isLeaf, i))
+	if err != nil {
+		return res, err
+	}
+	return types.BufferRead[V](bytes.NewReader(data))
+}
+
+func (s *indexSource[K, V]) Set(ctx context.Context, isLeaf bool, i K, value V) error {
+	data, err := types.HashBytes(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(ctx, s.key(isLeaf, i), data)
+}
+
+func (s *indexSource[K, V]) GetMany(ctx context.Context, keys []store.Key[K]) ([]V, error) {
+	res := make([]V, len(keys))
+	for i, key := range keys {
+		v, err := s.Get(ctx, key.IsLeaf, key.Index)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (s *indexSource[K, V]) SetMany(ctx context.Context, entries []store.Entry[K, V]) error {
+	ops := make([]Op, len(entries))
+	for i, e := range entries {
+		data, err := types.HashBytes(e.Value)
+		if err != nil {
+			return err
+		}
+		ops[i] = Op{Key: s.key(e.IsLeaf, e.Index), Value: data}
+	}
+	return s.db.Batch(ctx, ops)
+}
+
+func (s *indexSource[K, V]) Delete(ctx context.Context, keys []store.Key[K]) error {
+	ops := make([]Op, len(keys))
+	for i, key := range keys {
+		ops[i] = Op{Key: s.key(key.IsLeaf, key.Index), Delete: true}
+	}
+	return s.db.Batch(ctx, ops)
+}
+
+// Size returns 0 until the first SetSize.
+func (s *indexSource[K, V]) Size(ctx context.Context) (res K, err error) {
+	data, err := s.db.Get(ctx, s.sizeKey())
+	if errors.Is(err, types.ErrKeyNotFound) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	if len(data) != 8 {
+		return res, types.ErrTypeMismatch
+	}
+	return decodeIndex[K](binary.BigEndian.Uint64(data)), nil
+}
+
+func (s *indexSource[K, V]) SetSize(ctx context.Context, size K) error {
+	return s.db.Put(ctx, s.sizeKey(), binary.BigEndian.AppendUint64(nil, encodeIndex(size)))
+}
+
+func (s *indexSource[K, V]) sizeKey() []byte {
+	return append(bytes.Clone(s.namespace), sizeTag)
+}
+
+func (s *indexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	indexes, err := s.leafIndexes(ctx, leaf, true)
+	if err != nil {
+		return res, err
+	}
+	return indexes[0], nil
+}
+
+func (s *indexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
+	return s.leafIndexes(ctx, leaf, false)
+}
+
+// leafIndexes scans the leaves in the order of the indexes and collects the ones equal to the leaf.
+func (s *indexSource[K, V]) leafIndexes(ctx context.Context, leaf V, first bool) (res []K, err error) {
+	data, err := types.HashBytes(leaf)
+	if err != nil {
+		return nil, err
+	}
+	prefix := s.prefix(true)
+	if err = s.db.Iterate(ctx, prefix, func(key, value []byte) bool {
+		if bytes.Equal(value, data) && len(key) == len(prefix)+8 {
+			res = append(res, decodeIndex[K](binary.BigEndian.Uint64(key[len(prefix):])))
+			return !first
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+// encodeIndex maps the index to uint64 preserving the order, the sign bit of the signed types is flipped.
+func encodeIndex[K index.Value](i K) uint64 {
+	if isSigned[K]() {
+		return uint64(int64(i)) ^ (1 << 63)
+	}
+	return uint64(i)
+}
+
+func decodeIndex[K index.Value](v uint64) K {
+	if isSigned[K]() {
+		return K(int64(v ^ (1 << 63)))
+	}
+	return K(v)
+}
+
+func isSigned[K index.Value]() bool {
+	var zero K
+	return zero-1 < zero
+}
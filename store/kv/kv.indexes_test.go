@@ -0,0 +1,125 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/store/kv"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexSource_SetAndGet(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+	source := kv.IndexSource[uint32, types.Hash256](db, []byte("mmr/"))
+
+	assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+	assert.NoError(t, source.Set(ctx, false, 1, types.Hash256{2}))
+
+	res, err := source.Get(ctx, true, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{1}, res)
+	res, err = source.Get(ctx, false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{2}, res)
+
+	_, err = source.Get(ctx, true, 2)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	leafIndex, err := source.LeafIndex(ctx, types.Hash256{1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), leafIndex)
+	_, err = source.LeafIndex(ctx, types.Hash256{2})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "nodes should not be found as leaves")
+}
+
+func TestIndexSource_Delete(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+	source := kv.IndexSource[uint32, types.Hash256](db, []byte("mmr/"))
+
+	assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+	assert.NoError(t, source.Set(ctx, false, 1, types.Hash256{2}))
+	assert.NoError(t, store.Delete(ctx, source, []store.Key[uint32]{{IsLeaf: true, Index: 1}, {IsLeaf: true, Index: 7}}))
+
+	_, err := source.Get(ctx, true, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	_, err = source.LeafIndex(ctx, types.Hash256{1})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	res, err := source.Get(ctx, false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{2}, res)
+}
+
+func TestIndexSource_KeyOrder(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+	source := kv.IndexSource[int64, int64](db, nil)
+
+	for _, i := range []int64{300, -5, 0, 7, -300, 1 << 40} {
+		assert.NoError(t, source.Set(ctx, true, i, 42))
+	}
+	indexes, err := store.LeafIndexes(ctx, source, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-300, -5, 0, 7, 300, 1 << 40}, indexes, "leaves should be sorted by the index")
+}
+
+func TestIndexSource_Namespaces(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "db.log"))
+	defer db.Close()
+	a := kv.IndexSource[uint64, types.Hash256](db, []byte("a"))
+	b := kv.IndexSource[uint64, types.Hash256](db, []byte("b"))
+
+	assert.NoError(t, a.Set(ctx, true, 0, types.Hash256{1}))
+	_, err := b.Get(ctx, true, 0)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "namespaces should not share keys")
+	_, err = b.LeafIndex(ctx, types.Hash256{1})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestIndexSource_MountainRange(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.log")
+	db := openFile(t, path)
+	m := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, kv.IndexSource[uint64, types.Hash256](db, []byte("mmr")))
+
+	var hashes []types.Hash256
+	for i := 0; i < 13; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes...))
+	root, err := m.Root(ctx)
+	assert.NoError(t, err)
+
+	p, err := m.Proof(ctx, hashes[9])
+	assert.NoError(t, err)
+	assert.True(t, root.ValidateProof(p))
+	assert.NoError(t, db.Close())
+
+	// The reopened store serves the same leaves
+	db = openFile(t, path)
+	defer db.Close()
+	reopened := kv.IndexSource[uint64, types.Hash256](db, []byte("mmr"))
+	for i, h := range hashes {
+		res, err := reopened.Get(ctx, true, uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, h, res)
+	}
+
+	// and keeps the size for OpenMountainRange
+	m, err = merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, reopened)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(len(hashes)), m.Size())
+	reopenedRoot, err := m.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, root.Hash(), reopenedRoot.Hash())
+}
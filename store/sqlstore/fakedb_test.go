@@ -0,0 +1,316 @@
+package sqlstore_test
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeDB is an in-memory database/sql driver which understands the statements of the sqlstore.
+// Every table has a key and a value column, transactions are serialized and can be rolled back.
+type fakeDB struct {
+	mu     sync.Mutex
+	txMu   sync.Mutex
+	tables map[string]map[any]driver.Value
+
+	commits   int
+	rollbacks int
+	failExec  string
+}
+
+type fakeUndo struct {
+	table   string
+	key     any
+	value   driver.Value
+	existed bool
+}
+
+var (
+	fakeDriverOnce sync.Once
+	fakeDBsMu      sync.Mutex
+	fakeDBs        = map[string]*fakeDB{}
+
+	placeholderRe = regexp.MustCompile(`\$\d+`)
+	insertRe      = regexp.MustCompile(`^INSERT INTO (\w+) \((\w+), (\w+)\) VALUES \(\?, \?\)`)
+	updateIfRe    = regexp.MustCompile(`^UPDATE (\w+) SET \w+ = \? WHERE \w+ = \? AND \w+ = \?$`)
+	deleteInRe    = regexp.MustCompile(`^DELETE FROM (\w+) WHERE \w+ IN \(([?, ]+)\)$`)
+	selectInRe    = regexp.MustCompile(`^SELECT (\w+), (\w+) FROM (\w+) WHERE (\w+) IN \(([?, ]+)\)$`)
+	selectRangeRe = regexp.MustCompile(`^SELECT (\w+), (\w+) FROM (\w+) WHERE \w+ >= \? AND \w+ < \? ORDER BY \w+ LIMIT (\d+)$`)
+	selectByRe    = regexp.MustCompile(`^SELECT (\w+) FROM (\w+) WHERE (\w+) = \?( ORDER BY \w+)?( LIMIT (\d+))?$`)
+)
+
+// openFakeDB registers a new empty fake database and opens it.
+func openFakeDB(name string) (*sql.DB, *fakeDB) {
+	fakeDriverOnce.Do(func() {
+		sql.Register("fakedb", fakeDriver{})
+	})
+	db := &fakeDB{tables: map[string]map[any]driver.Value{}}
+	fakeDBsMu.Lock()
+	fakeDBs[name] = db
+	fakeDBsMu.Unlock()
+
+	conn, err := sql.Open("fakedb", name)
+	if err != nil {
+		panic(err)
+	}
+	return conn, db
+}
+
+func isKeyColumn(column string) bool {
+	return column == "idx" || column == "name"
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %s", name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db   *fakeDB
+	tx   *fakeTx
+	undo []fakeUndo
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: placeholderRe.ReplaceAllString(query, "?")}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.txMu.Lock()
+	c.tx = &fakeTx{conn: c}
+	c.undo = nil
+	return c.tx, nil
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t *fakeTx) Commit() error {
+	c := t.conn
+	c.db.mu.Lock()
+	c.db.commits++
+	c.db.mu.Unlock()
+	c.tx, c.undo = nil, nil
+	c.db.txMu.Unlock()
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	c := t.conn
+	c.db.mu.Lock()
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		u := c.undo[i]
+		if u.existed {
+			c.db.tables[u.table][u.key] = u.value
+		} else {
+			delete(c.db.tables[u.table], u.key)
+		}
+	}
+	c.db.rollbacks++
+	c.db.mu.Unlock()
+	c.tx, c.undo = nil, nil
+	c.db.txMu.Unlock()
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+// lock serializes the statements outside of a transaction with the running transactions.
+func (s *fakeStmt) lock() func() {
+	if s.conn.tx == nil {
+		s.conn.db.txMu.Lock()
+		s.conn.db.mu.Lock()
+		return func() {
+			s.conn.db.mu.Unlock()
+			s.conn.db.txMu.Unlock()
+		}
+	}
+	s.conn.db.mu.Lock()
+	return s.conn.db.mu.Unlock
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer s.lock()()
+	db := s.conn.db
+	if db.failExec != "" && strings.Contains(s.query, db.failExec) {
+		return nil, errors.New("exec failed")
+	}
+
+	if strings.HasPrefix(s.query, "CREATE ") {
+		return driver.RowsAffected(0), nil
+	}
+	if m := insertRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[1])
+		key := args[0]
+		prev, existed := table[key]
+		// An insert without the upsert clause fails on the existing key
+		if existed && len(m[0]) == len(s.query) {
+			return nil, fmt.Errorf("duplicate key %v", key)
+		}
+		if s.conn.tx != nil {
+			s.conn.undo = append(s.conn.undo, fakeUndo{table: m[1], key: key, value: prev, existed: existed})
+		}
+		table[key] = copyValue(args[1])
+		return driver.RowsAffected(1), nil
+	}
+	if m := updateIfRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[1])
+		key := args[1]
+		prev, existed := table[key]
+		if !existed || prev != args[2] {
+			return driver.RowsAffected(0), nil
+		}
+		if s.conn.tx != nil {
+			s.conn.undo = append(s.conn.undo, fakeUndo{table: m[1], key: key, value: prev, existed: true})
+		}
+		table[key] = args[0]
+		return driver.RowsAffected(1), nil
+	}
+	if m := deleteInRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[1])
+		var affected int64
+		for _, key := range args {
+			prev, existed := table[key]
+			if !existed {
+				continue
+			}
+			if s.conn.tx != nil {
+				s.conn.undo = append(s.conn.undo, fakeUndo{table: m[1], key: key, value: prev, existed: true})
+			}
+			delete(table, key)
+			affected++
+		}
+		return driver.RowsAffected(affected), nil
+	}
+	return nil, fmt.Errorf("unsupported statement: %s", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	defer s.lock()()
+	db := s.conn.db
+
+	if m := selectInRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[3])
+		rows := &fakeRows{columns: []string{m[1], m[2]}}
+		for _, key := range args {
+			if v, ok := table[key]; ok {
+				rows.data = append(rows.data, []driver.Value{key, v})
+			}
+		}
+		return rows, nil
+	}
+
+	if m := selectRangeRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[3])
+		rows := &fakeRows{columns: []string{m[1], m[2]}}
+		var keys []int64
+		for k := range table {
+			if i := k.(int64); i >= args[0].(int64) && i < args[1].(int64) {
+				keys = append(keys, i)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		limit, _ := strconv.Atoi(m[4])
+		for _, k := range keys[:min(limit, len(keys))] {
+			rows.data = append(rows.data, []driver.Value{k, table[k]})
+		}
+		return rows, nil
+	}
+
+	if m := selectByRe.FindStringSubmatch(s.query); m != nil {
+		table := db.table(m[2])
+		rows := &fakeRows{columns: []string{m[1]}}
+		if isKeyColumn(m[3]) {
+			if v, ok := table[args[0]]; ok {
+				rows.data = append(rows.data, []driver.Value{v})
+			}
+			return rows, nil
+		}
+
+		var keys []int64
+		for k, v := range table {
+			if b, ok := v.([]byte); ok && bytes.Equal(b, args[0].([]byte)) {
+				keys = append(keys, k.(int64))
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		if m[6] != "" {
+			limit, _ := strconv.Atoi(m[6])
+			keys = keys[:min(limit, len(keys))]
+		}
+		for _, k := range keys {
+			rows.data = append(rows.data, []driver.Value{k})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unsupported query: %s", s.query)
+}
+
+func (db *fakeDB) table(name string) map[any]driver.Value {
+	t, ok := db.tables[name]
+	if !ok {
+		t = map[any]driver.Value{}
+		db.tables[name] = t
+	}
+	return t
+}
+
+func copyValue(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok {
+		return bytes.Clone(b)
+	}
+	return v
+}
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+	return nil
+}
@@ -0,0 +1,84 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// IDialect generates the database specific SQL.
+type IDialect interface {
+	// Placeholder returns the n-th (1-based) query parameter.
+	Placeholder(n int) string
+	// Schema returns the statements creating the tables and indexes.
+	Schema(prefix string) []string
+	// Upsert returns the statement inserting or replacing the value column of the row with the key.
+	Upsert(table, key, value string) string
+}
+
+var (
+	// Postgres dialect uses $n parameters and ON CONFLICT upserts.
+	Postgres IDialect = postgres{}
+	// SQLite dialect uses ? parameters and ON CONFLICT upserts.
+	SQLite IDialect = sqlite{}
+	// MySQL dialect uses ? parameters and ON DUPLICATE KEY upserts.
+	MySQL IDialect = mysql{}
+)
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgres) Schema(prefix string) []string {
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_leaves (idx BIGINT PRIMARY KEY, hash BYTEA NOT NULL)", prefix),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_leaves_hash ON %s_leaves (hash)", prefix, prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_nodes (idx BIGINT PRIMARY KEY, hash BYTEA NOT NULL)", prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_meta (name VARCHAR(64) PRIMARY KEY, value BIGINT NOT NULL)", prefix),
+	}
+}
+
+func (d postgres) Upsert(table, key, value string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s, %s) ON CONFLICT (%s) DO UPDATE SET %s = excluded.%s",
+		table, key, value, d.Placeholder(1), d.Placeholder(2), key, value, value)
+}
+
+type sqlite struct{}
+
+func (sqlite) Placeholder(int) string {
+	return "?"
+}
+
+func (sqlite) Schema(prefix string) []string {
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_leaves (idx INTEGER PRIMARY KEY, hash BLOB NOT NULL)", prefix),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_leaves_hash ON %s_leaves (hash)", prefix, prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_nodes (idx INTEGER PRIMARY KEY, hash BLOB NOT NULL)", prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_meta (name TEXT PRIMARY KEY, value INTEGER NOT NULL)", prefix),
+	}
+}
+
+func (sqlite) Upsert(table, key, value string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (?, ?) ON CONFLICT (%s) DO UPDATE SET %s = excluded.%s",
+		table, key, value, key, value, value)
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(int) string {
+	return "?"
+}
+
+func (mysql) Schema(prefix string) []string {
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_leaves (idx BIGINT PRIMARY KEY, hash VARBINARY(64) NOT NULL, INDEX %s_leaves_hash (hash))", prefix, prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_nodes (idx BIGINT PRIMARY KEY, hash VARBINARY(64) NOT NULL)", prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_meta (name VARCHAR(64) PRIMARY KEY, value BIGINT NOT NULL)", prefix),
+	}
+}
+
+func (mysql) Upsert(table, key, value string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (?, ?) ON DUPLICATE KEY UPDATE %s = VALUES(%s)",
+		table, key, value, value, value)
+}
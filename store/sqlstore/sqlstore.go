@@ -0,0 +1,375 @@
+// Package sqlstore keeps the MMR in a database/sql database.
+//
+// Schema, where mmr is the table prefix:
+//
+//	mmr_leaves (idx BIGINT PRIMARY KEY, hash BLOB NOT NULL) - leaf hashes, indexed by hash for LeafIndex
+//	mmr_nodes  (idx BIGINT PRIMARY KEY, hash BLOB NOT NULL) - node hashes
+//	mmr_meta   (name VARCHAR(64) PRIMARY KEY, value BIGINT NOT NULL) - the 'size' row keeps the number of leaves
+//
+// The column types depend on the dialect, CreateSchema creates the tables if they do not exist.
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"math"
+	"strings"
+)
+
+// maxParams limits the number of parameters of a single query.
+const maxParams = 500
+
+const sizeRow = "size"
+
+// CreateSchema creates the tables with the prefix if they do not exist.
+func CreateSchema(ctx context.Context, db *sql.DB, dialect IDialect, prefix string) error {
+	for _, statement := range dialect.Schema(prefix) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type indexSource[K index.Value, V types.HashType] struct {
+	db      *sql.DB
+	dialect IDialect
+	leaves  string
+	nodes   string
+	meta    string
+}
+
+// IndexSource creates an index source over the tables with the prefix, see CreateSchema.
+// It also keeps the size of the MMR, see merkle.OpenMountainRange.
+func IndexSource[K index.Value, V types.HashType](db *sql.DB, dialect IDialect, prefix string) store.IIndexSource[K, V] {
+	return &indexSource[K, V]{
+		db:      db,
+		dialect: dialect,
+		leaves:  prefix + "_leaves",
+		nodes:   prefix + "_nodes",
+		meta:    prefix + "_meta",
+	}
+}
+
+func (s *indexSource[K, V]) table(isLeaf bool) string {
+	if isLeaf {
+		return s.leaves
+	}
+	return s.nodes
+}
+
+func (s *indexSource[K, V]) Get(ctx context.Context, isLeaf bool, i K) (res V, err error) {
+	var data []byte
+	query := fmt.Sprintf("SELECT hash FROM %s WHERE idx = %s", s.table(isLeaf), s.dialect.Placeholder(1))
+	if err = s.db.QueryRowContext(ctx, query, int64(i)).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = types.ErrKeyNotFound
+		}
+		return res, err
+	}
+	return types.BufferRead[V](bytes.NewReader(data))
+}
+
+func (s *indexSource[K, V]) Set(ctx context.Context, isLeaf bool, i K, value V) error {
+	data, err := types.HashBytes(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.Upsert(s.table(isLeaf), "idx", "hash"), int64(i), data)
+	return err
+}
+
+// GetMany reads the leaves and the nodes with IN queries of up to maxParams indexes.
+func (s *indexSource[K, V]) GetMany(ctx context.Context, keys []store.Key[K]) ([]V, error) {
+	found := make(map[store.Key[K]]V, len(keys))
+	for _, isLeaf := range []bool{true, false} {
+		var indexes []any
+		for _, key := range keys {
+			if key.IsLeaf == isLeaf {
+				indexes = append(indexes, int64(key.Index))
+			}
+		}
+		for len(indexes) > 0 {
+			chunk := indexes[:min(len(indexes), maxParams)]
+			indexes = indexes[len(chunk):]
+			if err := s.getChunk(ctx, isLeaf, chunk, found); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res := make([]V, len(keys))
+	for i, key := range keys {
+		v, ok := found[key]
+		if !ok {
+			return nil, types.ErrKeyNotFound
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (s *indexSource[K, V]) getChunk(ctx context.Context, isLeaf bool, indexes []any, found map[store.Key[K]]V) error {
+	params := make([]string, len(indexes))
+	for i := range indexes {
+		params[i] = s.dialect.Placeholder(i + 1)
+	}
+	query := fmt.Sprintf("SELECT idx, hash FROM %s WHERE idx IN (%s)", s.table(isLeaf), strings.Join(params, ", "))
+	rows, err := s.db.QueryContext(ctx, query, indexes...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i int64
+		var data []byte
+		if err = rows.Scan(&i, &data); err != nil {
+			return err
+		}
+		v, vErr := types.BufferRead[V](bytes.NewReader(data))
+		if vErr != nil {
+			return vErr
+		}
+		found[store.Key[K]{IsLeaf: isLeaf, Index: K(i)}] = v
+	}
+	return rows.Err()
+}
+
+// SetMany writes all the entries in a single transaction.
+func (s *indexSource[K, V]) SetMany(ctx context.Context, entries []store.Entry[K, V]) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = s.setMany(ctx, tx, entries); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *indexSource[K, V]) setMany(ctx context.Context, tx *sql.Tx, entries []store.Entry[K, V]) error {
+	statements := map[bool]*sql.Stmt{}
+	defer func() {
+		for _, stmt := range statements {
+			_ = stmt.Close()
+		}
+	}()
+
+	for _, e := range entries {
+		stmt, ok := statements[e.IsLeaf]
+		if !ok {
+			var err error
+			if stmt, err = tx.PrepareContext(ctx, s.dialect.Upsert(s.table(e.IsLeaf), "idx", "hash")); err != nil {
+				return err
+			}
+			statements[e.IsLeaf] = stmt
+		}
+		data, err := types.HashBytes(e.Value)
+		if err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, int64(e.Index), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the leaves and the nodes with IN statements of up to maxParams indexes in a single transaction.
+func (s *indexSource[K, V]) Delete(ctx context.Context, keys []store.Key[K]) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = s.deleteMany(ctx, tx, keys); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *indexSource[K, V]) deleteMany(ctx context.Context, tx *sql.Tx, keys []store.Key[K]) error {
+	for _, isLeaf := range []bool{true, false} {
+		var indexes []any
+		for _, key := range keys {
+			if key.IsLeaf == isLeaf {
+				indexes = append(indexes, int64(key.Index))
+			}
+		}
+		for len(indexes) > 0 {
+			chunk := indexes[:min(len(indexes), maxParams)]
+			indexes = indexes[len(chunk):]
+			params := make([]string, len(chunk))
+			for i := range chunk {
+				params[i] = s.dialect.Placeholder(i + 1)
+			}
+			statement := fmt.Sprintf("DELETE FROM %s WHERE idx IN (%s)", s.table(isLeaf), strings.Join(params, ", "))
+			if _, err := tx.ExecContext(ctx, statement, chunk...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *indexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	indexes, err := s.leafIndexes(ctx, leaf, " LIMIT 1")
+	if err != nil {
+		return res, err
+	}
+	return indexes[0], nil
+}
+
+// ScanLeaves reads the leaves with range queries of up to maxParams rows.
+func (s *indexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	start, end := int64(max(from, 0)), int64(to)
+	if to > 0 && end < 0 {
+		end = math.MaxInt64
+	}
+	query := fmt.Sprintf("SELECT idx, hash FROM %s WHERE idx >= %s AND idx < %s ORDER BY idx LIMIT %d",
+		s.leaves, s.dialect.Placeholder(1), s.dialect.Placeholder(2), maxParams)
+	for start < end {
+		indexes, values, err := s.scanPage(ctx, query, start, end)
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			if !fn(K(indexes[i]), v) {
+				return nil
+			}
+		}
+		if len(indexes) < maxParams {
+			return nil
+		}
+		start = indexes[len(indexes)-1] + 1
+	}
+	return nil
+}
+
+func (s *indexSource[K, V]) scanPage(ctx context.Context, query string, start, end int64) ([]int64, []V, error) {
+	rows, err := s.db.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var indexes []int64
+	var values []V
+	for rows.Next() {
+		var i int64
+		var data []byte
+		if err = rows.Scan(&i, &data); err != nil {
+			return nil, nil, err
+		}
+		v, vErr := types.BufferRead[V](bytes.NewReader(data))
+		if vErr != nil {
+			return nil, nil, vErr
+		}
+		indexes = append(indexes, i)
+		values = append(values, v)
+	}
+	return indexes, values, rows.Err()
+}
+
+func (s *indexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
+	return s.leafIndexes(ctx, leaf, "")
+}
+
+func (s *indexSource[K, V]) leafIndexes(ctx context.Context, leaf V, limit string) ([]K, error) {
+	data, err := types.HashBytes(leaf)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT idx FROM %s WHERE hash = %s ORDER BY idx%s", s.leaves, s.dialect.Placeholder(1), limit)
+	rows, err := s.db.QueryContext(ctx, query, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []K
+	for rows.Next() {
+		var i int64
+		if err = rows.Scan(&i); err != nil {
+			return nil, err
+		}
+		res = append(res, K(i))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+// Size returns the size kept in the metadata table, 0 if it was never set.
+func (s *indexSource[K, V]) Size(ctx context.Context) (K, error) {
+	var size int64
+	query := fmt.Sprintf("SELECT value FROM %s WHERE name = %s", s.meta, s.dialect.Placeholder(1))
+	if err := s.db.QueryRowContext(ctx, query, sizeRow).Scan(&size); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return K(size), nil
+}
+
+func (s *indexSource[K, V]) SetSize(ctx context.Context, size K) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Upsert(s.meta, "name", "value"), sizeRow, int64(size))
+	return err
+}
+
+// SetManyIfSize updates the size row only if it keeps the expected size and writes the entries in the same transaction.
+// The conditional update locks the row, so the appends of the processes sharing the database are serialized.
+func (s *indexSource[K, V]) SetManyIfSize(ctx context.Context, expected, size K, entries []store.Entry[K, V]) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	swapped, err := s.swapSize(ctx, tx, expected, size)
+	if err == nil && swapped {
+		err = s.setMany(ctx, tx, entries)
+	}
+	if err != nil || !swapped {
+		_ = tx.Rollback()
+		// A failed insert of the first size row means another process has just created it
+		actual, sizeErr := s.Size(ctx)
+		if sizeErr == nil && (err == nil || actual != expected) {
+			return &store.SizeConflictError[K]{Expected: expected, Actual: actual}
+		}
+		if err == nil {
+			err = sizeErr
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// swapSize replaces the expected size with the new one. The size row is missing until the first append,
+// it is inserted without the upsert then, so only one of the concurrent inserts succeeds.
+func (s *indexSource[K, V]) swapSize(ctx context.Context, tx *sql.Tx, expected, size K) (bool, error) {
+	update := fmt.Sprintf("UPDATE %s SET value = %s WHERE name = %s AND value = %s",
+		s.meta, s.dialect.Placeholder(1), s.dialect.Placeholder(2), s.dialect.Placeholder(3))
+	res, err := tx.ExecContext(ctx, update, int64(size), sizeRow, int64(expected))
+	if err != nil {
+		return false, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+		return affected > 0, err
+	}
+	if expected != 0 {
+		return false, nil
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (name, value) VALUES (%s, %s)", s.meta, s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+	if _, err = tx.ExecContext(ctx, insert, sizeRow, int64(size)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
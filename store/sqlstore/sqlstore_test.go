@@ -0,0 +1,289 @@
+package sqlstore_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dk-open/go-mmr/merkle"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/store/sqlstore"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/dk-open/go-mmr/types/hasher"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func newIndexSource(t *testing.T, dialect sqlstore.IDialect) (store.IIndexSource[uint64, types.Hash256], *fakeDB) {
+	conn, db := openFakeDB(t.Name())
+	t.Cleanup(func() { _ = conn.Close() })
+	if err := sqlstore.CreateSchema(context.Background(), conn, dialect, "mmr"); err != nil {
+		t.Fatalf("failed to create the schema: %v", err)
+	}
+	return sqlstore.IndexSource[uint64, types.Hash256](conn, dialect, "mmr"), db
+}
+
+func TestIndexSource_SetAndGet(t *testing.T) {
+	for name, dialect := range map[string]sqlstore.IDialect{"postgres": sqlstore.Postgres, "sqlite": sqlstore.SQLite, "mysql": sqlstore.MySQL} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			source, _ := newIndexSource(t, dialect)
+
+			assert.NoError(t, source.Set(ctx, true, 3, types.Hash256{1}))
+			assert.NoError(t, source.Set(ctx, false, 3, types.Hash256{2}))
+			assert.NoError(t, source.Set(ctx, true, 3, types.Hash256{3}), "upsert should overwrite the leaf")
+
+			res, err := source.Get(ctx, true, 3)
+			assert.NoError(t, err)
+			assert.Equal(t, types.Hash256{3}, res)
+			res, err = source.Get(ctx, false, 3)
+			assert.NoError(t, err)
+			assert.Equal(t, types.Hash256{2}, res)
+
+			_, err = source.Get(ctx, true, 4)
+			assert.ErrorIs(t, err, types.ErrKeyNotFound)
+		})
+	}
+}
+
+func TestIndexSource_Batch(t *testing.T) {
+	ctx := context.Background()
+	source, db := newIndexSource(t, sqlstore.Postgres)
+
+	entries := []store.Entry[uint64, types.Hash256]{
+		{Key: store.Key[uint64]{IsLeaf: true, Index: 0}, Value: types.Hash256{1}},
+		{Key: store.Key[uint64]{IsLeaf: true, Index: 1}, Value: types.Hash256{2}},
+		{Key: store.Key[uint64]{IsLeaf: false, Index: 1}, Value: types.Hash256{3}},
+	}
+	assert.NoError(t, store.SetMany(ctx, source, entries))
+	assert.Equal(t, 1, db.commits, "entries should be written in a single transaction")
+
+	values, err := store.GetMany(ctx, source, []store.Key[uint64]{{IsLeaf: false, Index: 1}, {IsLeaf: true, Index: 0}, {IsLeaf: true, Index: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Hash256{{3}, {1}, {2}}, values)
+
+	_, err = store.GetMany(ctx, source, []store.Key[uint64]{{IsLeaf: true, Index: 0}, {IsLeaf: true, Index: 5}})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	// A failed write rolls back the whole batch
+	db.failExec = "mmr_nodes"
+	err = store.SetMany(ctx, source, []store.Entry[uint64, types.Hash256]{
+		{Key: store.Key[uint64]{IsLeaf: true, Index: 2}, Value: types.Hash256{4}},
+		{Key: store.Key[uint64]{IsLeaf: false, Index: 3}, Value: types.Hash256{5}},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, db.rollbacks)
+	_, err = source.Get(ctx, true, 2)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "leaf of the failed batch should be rolled back")
+}
+
+func TestIndexSource_Delete(t *testing.T) {
+	ctx := context.Background()
+	source, db := newIndexSource(t, sqlstore.SQLite)
+
+	assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+	assert.NoError(t, source.Set(ctx, false, 1, types.Hash256{2}))
+	assert.NoError(t, source.Set(ctx, false, 2, types.Hash256{3}))
+	keys := []store.Key[uint64]{{IsLeaf: true, Index: 1}, {IsLeaf: false, Index: 1}, {IsLeaf: false, Index: 9}}
+	assert.NoError(t, store.Delete(ctx, source, keys))
+	assert.Equal(t, 1, db.commits, "keys should be deleted in a single transaction")
+
+	_, err := source.Get(ctx, true, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	_, err = source.Get(ctx, false, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	res, err := source.Get(ctx, false, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{3}, res)
+
+	// A failed statement rolls back the whole deletion
+	assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+	db.failExec = "DELETE FROM mmr_nodes"
+	assert.Error(t, store.Delete(ctx, source, []store.Key[uint64]{{IsLeaf: true, Index: 1}, {IsLeaf: false, Index: 2}}))
+	db.failExec = ""
+	_, err = source.Get(ctx, true, 1)
+	assert.NoError(t, err, "leaf should be restored by the rollback")
+}
+
+func TestIndexSource_ScanLeaves(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newIndexSource(t, sqlstore.MySQL)
+	entries := make([]store.Entry[uint64, types.Hash256], 0, 1200)
+	for i := uint64(0); i < 1200; i++ {
+		if i != 700 {
+			entries = append(entries, store.Entry[uint64, types.Hash256]{Key: store.Key[uint64]{IsLeaf: true, Index: i}, Value: types.Hash256{byte(i), byte(i >> 8)}})
+		}
+	}
+	assert.NoError(t, store.SetMany(ctx, source, entries))
+
+	var indexes []uint64
+	assert.NoError(t, store.ScanLeaves(ctx, source, 100, math.MaxUint64, func(i uint64, leaf types.Hash256) bool {
+		assert.Equal(t, types.Hash256{byte(i), byte(i >> 8)}, leaf)
+		indexes = append(indexes, i)
+		return true
+	}))
+	assert.Len(t, indexes, 1099, "scan should continue over the pages and skip the missing leaf")
+	assert.Equal(t, uint64(100), indexes[0])
+	assert.Equal(t, uint64(1199), indexes[len(indexes)-1])
+}
+
+func TestIndexSource_SetManyIfSize(t *testing.T) {
+	ctx := context.Background()
+	source, db := newIndexSource(t, sqlstore.Postgres)
+	conditional := source.(store.IConditionalSetter[uint64, types.Hash256])
+	leaf := func(i uint64) []store.Entry[uint64, types.Hash256] {
+		return []store.Entry[uint64, types.Hash256]{{Key: store.Key[uint64]{IsLeaf: true, Index: i}, Value: types.Hash256{byte(i + 1)}}}
+	}
+
+	assert.NoError(t, conditional.SetManyIfSize(ctx, 0, 1, leaf(0)), "the first write should create the size row")
+	var conflict *store.SizeConflictError[uint64]
+	err := conditional.SetManyIfSize(ctx, 0, 1, leaf(1))
+	assert.ErrorIs(t, err, types.ErrSizeConflict)
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, uint64(0), conflict.Expected)
+		assert.Equal(t, uint64(1), conflict.Actual)
+	}
+	_, err = source.Get(ctx, true, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "entries of the conflicting write should be rolled back")
+
+	assert.NoError(t, conditional.SetManyIfSize(ctx, 1, 2, leaf(1)))
+	err = conditional.SetManyIfSize(ctx, 1, 2, leaf(2))
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, uint64(2), conflict.Actual)
+	}
+
+	// A failed write keeps the size
+	db.failExec = "mmr_leaves"
+	assert.Error(t, conditional.SetManyIfSize(ctx, 2, 3, leaf(2)))
+	db.failExec = ""
+	size, err := source.(store.ISizeSource[uint64]).Size(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), size)
+}
+
+func TestIndexSource_SharedMountainRanges(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newIndexSource(t, sqlstore.SQLite)
+	var hashes []types.Hash256
+	for i := 0; i < 9; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+
+	// Two writers sharing the database, e.g. two processes
+	first, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	second, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+
+	assert.NoError(t, first.AddIfSize(ctx, 0, hashes[:4]...))
+	err = second.AddIfSize(ctx, 0, hashes[4])
+	var conflict *store.SizeConflictError[uint64]
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, uint64(4), conflict.Actual)
+	}
+
+	// A plain Add of a stale writer fails instead of overwriting the leaves
+	assert.NoError(t, first.Add(ctx, hashes[4:6]...))
+	assert.ErrorIs(t, second.Add(ctx, hashes[6]), types.ErrSizeConflict)
+	assert.Equal(t, uint64(6), second.Size(), "conflict should refresh the size")
+	assert.NoError(t, second.AddIfSize(ctx, 6, hashes[6:]...))
+
+	expected := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	assert.NoError(t, expected.Add(ctx, hashes...))
+	expectedRoot, err := expected.Root(ctx)
+	assert.NoError(t, err)
+	reopened, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	root, err := reopened.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot.Hash(), root.Hash())
+}
+
+func TestIndexSource_LeafIndexes(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newIndexSource(t, sqlstore.SQLite)
+
+	for _, i := range []uint64{5, 2, 9} {
+		assert.NoError(t, source.Set(ctx, true, i, types.Hash256{7}))
+	}
+	leafIndex, err := source.LeafIndex(ctx, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), leafIndex)
+
+	indexes, err := store.LeafIndexes(ctx, source, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 5, 9}, indexes)
+
+	_, err = source.LeafIndex(ctx, types.Hash256{8})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestIndexSource_ReopenMountainRange(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newIndexSource(t, sqlstore.Postgres)
+
+	m, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), m.Size())
+
+	var hashes []types.Hash256
+	for i := 0; i < 11; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+	assert.NoError(t, m.Add(ctx, hashes[:6]...))
+
+	reopened, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, source)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), reopened.Size(), "size should be read from the metadata")
+	assert.NoError(t, reopened.Add(ctx, hashes[6:]...))
+
+	expected := merkle.NewMountainRange[uint64, types.Hash256](hasher.Sha3_256, store.MemoryIndexSource[uint64, types.Hash256]())
+	assert.NoError(t, expected.Add(ctx, hashes...))
+	expectedRoot, err := expected.Root(ctx)
+	assert.NoError(t, err)
+	root, err := reopened.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot.Hash(), root.Hash())
+
+	p, err := reopened.Proof(ctx, hashes[4])
+	assert.NoError(t, err)
+	assert.True(t, root.ValidateProof(p))
+}
+
+func TestIndexSource_DecoratedMountainRanges(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newIndexSource(t, sqlstore.SQLite)
+	decorated := func() store.IIndexSource[uint64, types.Hash256] {
+		size, err := store.Size(ctx, source)
+		assert.NoError(t, err)
+		res, err := store.LeafIndexed(ctx, store.Cached(source, 16), size)
+		assert.NoError(t, err)
+		return res
+	}
+	var hashes []types.Hash256
+	for i := 0; i < 6; i++ {
+		hashes = append(hashes, hasher.Sha3_256([]byte(fmt.Sprintf("test data %d", i))))
+	}
+
+	first, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, decorated())
+	assert.NoError(t, err)
+	assert.NoError(t, first.AddIfSize(ctx, 0, hashes[:4]...))
+
+	// The decorators forward the size, so the second writer opens at the stored one
+	second, err := merkle.OpenMountainRange[uint64, types.Hash256](ctx, hasher.Sha3_256, decorated())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), second.Size())
+
+	// and the conditional write, so the stale writer can't overwrite the leaves
+	assert.NoError(t, first.Add(ctx, hashes[4]))
+	assert.ErrorIs(t, second.Add(ctx, hashes[5]), types.ErrSizeConflict, "plain Add should use the conditional write")
+	err = second.AddIfSize(ctx, 4, hashes[5])
+	var conflict *store.SizeConflictError[uint64]
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, uint64(5), conflict.Actual)
+	}
+	assert.NoError(t, second.AddIfSize(ctx, 5, hashes[5]))
+
+	size, err := store.Size(ctx, source)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), size)
+}
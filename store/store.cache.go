@@ -0,0 +1,244 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"math/bits"
+	"sync"
+)
+
+// cacheLevels is the number of priority levels: leaves and every possible node height.
+const cacheLevels = 66
+
+// CacheStats is the statistics of the cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// ICacheStats is implemented by the caching sources.
+type ICacheStats interface {
+	Stats() CacheStats
+}
+
+// cacheLevel returns the priority of the key: 0 for leaves, node height + 1 for nodes.
+func cacheLevel[K index.Value](key Key[K]) int {
+	if key.IsLeaf {
+		return 0
+	}
+	return bits.TrailingZeros64(uint64(key.Index)) + 1
+}
+
+type cacheEntry[K index.Value, V types.HashType] struct {
+	key   Key[K]
+	value V
+}
+
+type cachedIndexSource[K index.Value, V types.HashType] struct {
+	IIndexSource[K, V]
+	sync.Mutex
+	capacity int
+	entries  map[Key[K]]*list.Element
+	// levels keeps an LRU list per priority level, the lower levels are evicted first.
+	levels [cacheLevels]list.List
+	// latest is the last written key of every level. Nodes of the same height are written
+	// from left to right, so the latest ones include every peak and are never evicted.
+	latest [cacheLevels]*Key[K]
+	// deletes counts the deletes, a value read from the source during a delete is not cached.
+	deletes uint64
+	stats   CacheStats
+}
+
+// Cached wraps the source with a write-through cache of up to capacity values.
+// The leaves are evicted before the nodes and the lower nodes before the upper ones, because
+// every proof and root reads the upper nodes and the peaks. The latest written value of every level
+// is pinned, so a cache smaller than the number of levels may exceed the capacity.
+func Cached[K index.Value, V types.HashType](source IIndexSource[K, V], capacity int) IIndexSource[K, V] {
+	return &cachedIndexSource[K, V]{
+		IIndexSource: source,
+		capacity:     capacity,
+		entries:      make(map[Key[K]]*list.Element, capacity),
+	}
+}
+
+func (c *cachedIndexSource[K, V]) Get(ctx context.Context, isLeaf bool, index K) (V, error) {
+	key := Key[K]{IsLeaf: isLeaf, Index: index}
+	c.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.levels[cacheLevel(key)].MoveToFront(el)
+		c.stats.Hits++
+		res := el.Value.(*cacheEntry[K, V]).value
+		c.Unlock()
+		return res, nil
+	}
+	c.stats.Misses++
+	deletes := c.deletes
+	c.Unlock()
+
+	res, err := c.IIndexSource.Get(ctx, isLeaf, index)
+	if err != nil {
+		return res, err
+	}
+	c.Lock()
+	// Keep the value if it was written while the source was read
+	if _, ok := c.entries[key]; !ok && c.deletes == deletes {
+		c.put(key, res)
+	}
+	c.Unlock()
+	return res, nil
+}
+
+func (c *cachedIndexSource[K, V]) Set(ctx context.Context, isLeaf bool, index K, value V) error {
+	if err := c.IIndexSource.Set(ctx, isLeaf, index, value); err != nil {
+		return err
+	}
+	key := Key[K]{IsLeaf: isLeaf, Index: index}
+	c.Lock()
+	c.latest[cacheLevel(key)] = &key
+	c.put(key, value)
+	c.Unlock()
+	return nil
+}
+
+func (c *cachedIndexSource[K, V]) GetMany(ctx context.Context, keys []Key[K]) ([]V, error) {
+	res := make([]V, len(keys))
+	var missing []Key[K]
+	var missingAt []int
+	c.Lock()
+	for i, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.levels[cacheLevel(key)].MoveToFront(el)
+			c.stats.Hits++
+			res[i] = el.Value.(*cacheEntry[K, V]).value
+		} else {
+			c.stats.Misses++
+			missing = append(missing, key)
+			missingAt = append(missingAt, i)
+		}
+	}
+	deletes := c.deletes
+	c.Unlock()
+	if len(missing) == 0 {
+		return res, nil
+	}
+
+	values, err := GetMany(ctx, c.IIndexSource, missing)
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	for i, v := range values {
+		res[missingAt[i]] = v
+		if _, ok := c.entries[missing[i]]; !ok && c.deletes == deletes {
+			c.put(missing[i], v)
+		}
+	}
+	c.Unlock()
+	return res, nil
+}
+
+func (c *cachedIndexSource[K, V]) SetMany(ctx context.Context, entries []Entry[K, V]) error {
+	if err := SetMany(ctx, c.IIndexSource, entries); err != nil {
+		return err
+	}
+	c.putWritten(entries)
+	return nil
+}
+
+func (c *cachedIndexSource[K, V]) Delete(ctx context.Context, keys []Key[K]) error {
+	if err := Delete(ctx, c.IIndexSource, keys); err != nil {
+		return err
+	}
+	c.Lock()
+	c.deletes++
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.levels[cacheLevel(key)].Remove(el)
+			delete(c.entries, key)
+		}
+	}
+	c.Unlock()
+	return nil
+}
+
+// SetManyIfSize forwards the conditional write, the cache is updated only when the source accepts it.
+func (c *cachedIndexSource[K, V]) SetManyIfSize(ctx context.Context, expected, size K, entries []Entry[K, V]) error {
+	if err := SetManyIfSize(ctx, c.IIndexSource, expected, size, entries); err != nil {
+		return err
+	}
+	c.putWritten(entries)
+	return nil
+}
+
+// Size is not cached, as other processes sharing the source may change it.
+func (c *cachedIndexSource[K, V]) Size(ctx context.Context) (K, error) {
+	return Size(ctx, c.IIndexSource)
+}
+
+func (c *cachedIndexSource[K, V]) SetSize(ctx context.Context, size K) error {
+	return SetSize(ctx, c.IIndexSource, size)
+}
+
+// ScanLeaves reads the leaves from the source, so a scan does not evict the cached values.
+func (c *cachedIndexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	return ScanLeaves(ctx, c.IIndexSource, from, to, fn)
+}
+
+func (c *cachedIndexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
+	return LeafIndexes(ctx, c.IIndexSource, leaf)
+}
+
+func (c *cachedIndexSource[K, V]) Stats() CacheStats {
+	c.Lock()
+	defer c.Unlock()
+	res := c.stats
+	res.Size = len(c.entries)
+	return res
+}
+
+// putWritten adds the written entries and pins them as the latest of their levels.
+func (c *cachedIndexSource[K, V]) putWritten(entries []Entry[K, V]) {
+	c.Lock()
+	defer c.Unlock()
+	for _, e := range entries {
+		key := e.Key
+		c.latest[cacheLevel(key)] = &key
+		c.put(key, e.Value)
+	}
+}
+
+// put adds or updates the value and evicts the values over the capacity.
+func (c *cachedIndexSource[K, V]) put(key Key[K], value V) {
+	level := &c.levels[cacheLevel(key)]
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry[K, V]).value = value
+		level.MoveToFront(el)
+		return
+	}
+	c.entries[key] = level.PushFront(&cacheEntry[K, V]{key: key, value: value})
+
+	for len(c.entries) > c.capacity && c.evict() {
+	}
+}
+
+// evict removes the least recently used value of the lowest level, skipping the pinned latest values.
+func (c *cachedIndexSource[K, V]) evict() bool {
+	for i := range c.levels {
+		level := &c.levels[i]
+		for el := level.Back(); el != nil; el = el.Prev() {
+			entry := el.Value.(*cacheEntry[K, V])
+			if latest := c.latest[i]; latest != nil && *latest == entry.key {
+				continue
+			}
+			level.Remove(el)
+			delete(c.entries, entry.key)
+			c.stats.Evictions++
+			return true
+		}
+	}
+	return false
+}
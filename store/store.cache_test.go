@@ -0,0 +1,143 @@
+package store_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// countingIndexSource counts the reads which reach the wrapped source.
+type countingIndexSource struct {
+	store.IIndexSource[uint32, types.Hash256]
+	gets int
+}
+
+func (c *countingIndexSource) Get(ctx context.Context, isLeaf bool, index uint32) (types.Hash256, error) {
+	c.gets++
+	return c.IIndexSource.Get(ctx, isLeaf, index)
+}
+
+func TestCached_WriteThroughAndStats(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingIndexSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+	cache := store.Cached[uint32, types.Hash256](backend, 4)
+
+	assert.NoError(t, cache.Set(ctx, true, 1, types.Hash256{1}))
+	res, err := backend.Get(ctx, true, 1)
+	assert.NoError(t, err, "value should be written to the backend")
+	assert.Equal(t, types.Hash256{1}, res)
+	backend.gets = 0
+
+	res, err = cache.Get(ctx, true, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Hash256{1}, res)
+	assert.Equal(t, 0, backend.gets, "written value should be served from the cache")
+
+	_, err = cache.Get(ctx, true, 2)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	assert.Equal(t, 1, backend.gets)
+
+	stats := cache.(store.ICacheStats).Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Size)
+
+	leafIndex, err := cache.LeafIndex(ctx, types.Hash256{1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), leafIndex)
+}
+
+func TestCached_EvictsLowerLevelsFirst(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingIndexSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+	cache := store.Cached[uint32, types.Hash256](backend, 3)
+
+	assert.NoError(t, cache.Set(ctx, false, 4, types.Hash256{4})) // height 2
+	assert.NoError(t, cache.Set(ctx, false, 2, types.Hash256{2})) // height 1
+	for i := uint32(0); i < 4; i++ {
+		assert.NoError(t, cache.Set(ctx, true, i, types.Hash256{byte(i)}))
+	}
+
+	stats := cache.(store.ICacheStats).Stats()
+	assert.Equal(t, 3, stats.Size)
+	assert.Equal(t, uint64(3), stats.Evictions)
+
+	backend.gets = 0
+	for _, i := range []uint32{4, 2} {
+		_, err := cache.Get(ctx, false, i)
+		assert.NoError(t, err)
+	}
+	_, err := cache.Get(ctx, true, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, backend.gets, "upper nodes and the latest leaf should stay in the cache")
+
+	_, err = cache.Get(ctx, true, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, backend.gets, "older leaves should be evicted")
+}
+
+func TestCached_KeepsLatestOfEveryLevel(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingIndexSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+	cache := store.Cached[uint32, types.Hash256](backend, 2)
+
+	// The latest leaf is a peak, so it is kept even when the upper nodes fill the cache.
+	assert.NoError(t, cache.Set(ctx, true, 6, types.Hash256{6}))
+	assert.NoError(t, cache.Set(ctx, false, 4, types.Hash256{4}))
+	assert.NoError(t, cache.Set(ctx, false, 5, types.Hash256{5}))
+
+	backend.gets = 0
+	_, err := cache.Get(ctx, true, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, backend.gets, "latest leaf should not be evicted")
+}
+
+// deletedDuringGetSource runs onGet after the first read of the source, before the value is returned.
+type deletedDuringGetSource struct {
+	store.IIndexSource[uint32, types.Hash256]
+	onGet func()
+}
+
+func (s *deletedDuringGetSource) Get(ctx context.Context, isLeaf bool, index uint32) (types.Hash256, error) {
+	res, err := s.IIndexSource.Get(ctx, isLeaf, index)
+	if onGet := s.onGet; onGet != nil {
+		s.onGet = nil
+		onGet()
+	}
+	return res, err
+}
+
+func (s *deletedDuringGetSource) Delete(ctx context.Context, keys []store.Key[uint32]) error {
+	return store.Delete(ctx, s.IIndexSource, keys)
+}
+
+func TestCached_DeleteDuringGet(t *testing.T) {
+	ctx := context.Background()
+	keys := []store.Key[uint32]{{IsLeaf: true, Index: 1}}
+	reads := map[string]func(cache store.IIndexSource[uint32, types.Hash256]) error{
+		"Get": func(cache store.IIndexSource[uint32, types.Hash256]) error {
+			_, err := cache.Get(ctx, true, 1)
+			return err
+		},
+		"GetMany": func(cache store.IIndexSource[uint32, types.Hash256]) error {
+			_, err := store.GetMany(ctx, cache, keys)
+			return err
+		},
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			backend := &deletedDuringGetSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+			assert.NoError(t, backend.Set(ctx, true, 1, types.Hash256{1}))
+			cache := store.Cached[uint32, types.Hash256](backend, 4)
+			backend.onGet = func() {
+				assert.NoError(t, store.Delete(ctx, cache, keys))
+			}
+
+			assert.NoError(t, read(cache), "the value was read before the delete")
+			_, err := cache.Get(ctx, true, 1)
+			assert.ErrorIs(t, err, types.ErrKeyNotFound, "deleted value should not be cached")
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+// DefaultPageSize is the number of values in a page of the dense source.
+const DefaultPageSize = 1 << 16
+
+// IMemoryUsage is implemented by the sources which can report their memory footprint.
+type IMemoryUsage interface {
+	// MemoryUsage returns the number of bytes allocated for the stored values.
+	MemoryUsage() uint64
+}
+
+// densePage keeps the values of a page and the bitmap of the values which were set.
+type densePage[V types.HashType] struct {
+	values  []V
+	present []uint64
+}
+
+// densePages is a chunked array which grows in pages, so appending never copies the stored values.
+type densePages[V types.HashType] struct {
+	pageSize int
+	pages    []*densePage[V]
+}
+
+func (d *densePages[V]) locate(i uint64) (page uint64, offset int) {
+	return i / uint64(d.pageSize), int(i % uint64(d.pageSize))
+}
+
+func (d *densePages[V]) get(i uint64) (res V, ok bool) {
+	p, offset := d.locate(i)
+	if p >= uint64(len(d.pages)) || d.pages[p] == nil {
+		return res, false
+	}
+	page := d.pages[p]
+	if page.present[offset/64]&(1<<(offset%64)) == 0 {
+		return res, false
+	}
+	return page.values[offset], true
+}
+
+func (d *densePages[V]) set(i uint64, value V) {
+	p, offset := d.locate(i)
+	for uint64(len(d.pages)) <= p {
+		d.pages = append(d.pages, nil)
+	}
+	page := d.pages[p]
+	if page == nil {
+		page = &densePage[V]{
+			values:  make([]V, d.pageSize),
+			present: make([]uint64, (d.pageSize+63)/64),
+		}
+		d.pages[p] = page
+	}
+	page.values[offset] = value
+	page.present[offset/64] |= 1 << (offset % 64)
+}
+
+func (d *densePages[V]) remove(i uint64) {
+	p, offset := d.locate(i)
+	if p < uint64(len(d.pages)) && d.pages[p] != nil {
+		d.pages[p].present[offset/64] &^= 1 << (offset % 64)
+	}
+}
+
+// scan calls f for every value which was set, in the order of the indexes.
+func (d *densePages[V]) scan(f func(i uint64, value V) bool) {
+	for p, page := range d.pages {
+		if page == nil {
+			continue
+		}
+		for w, word := range page.present {
+			for word != 0 {
+				offset := w*64 + bits.TrailingZeros64(word)
+				if !f(uint64(p)*uint64(d.pageSize)+uint64(offset), page.values[offset]) {
+					return
+				}
+				word &= word - 1
+			}
+		}
+	}
+}
+
+func (d *densePages[V]) memoryUsage() (res uint64) {
+	var v V
+	res = uint64(cap(d.pages)) * uint64(unsafe.Sizeof(uintptr(0)))
+	for _, page := range d.pages {
+		if page != nil {
+			res += uint64(unsafe.Sizeof(*page)) + uint64(len(page.values))*uint64(unsafe.Sizeof(v)) + uint64(len(page.present))*8
+		}
+	}
+	return res
+}
+
+type denseIndexSource[K index.Value, V types.HashType] struct {
+	sync.RWMutex
+	leafs densePages[V]
+	nodes densePages[V]
+}
+
+// DenseIndexSource creates an in-memory source backed by pages of pageSize values instead of maps.
+// Leaf and node indexes are dense, so it needs far less memory than MemoryIndexSource for large MMRs.
+// LeafIndex scans the leaves, wrap the source with LeafIndexed when the lookups by hash are frequent.
+func DenseIndexSource[K index.Value, V types.HashType](pageSize int) IIndexSource[K, V] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &denseIndexSource[K, V]{
+		leafs: densePages[V]{pageSize: pageSize},
+		nodes: densePages[V]{pageSize: pageSize},
+	}
+}
+
+func (a *denseIndexSource[K, V]) pages(isLeaf bool) *densePages[V] {
+	if isLeaf {
+		return &a.leafs
+	}
+	return &a.nodes
+}
+
+func (a *denseIndexSource[K, V]) Set(ctx context.Context, isLeaf bool, index K, value V) error {
+	if index < 0 {
+		return types.ErrIndexOutOfRange
+	}
+	a.Lock()
+	a.pages(isLeaf).set(uint64(index), value)
+	a.Unlock()
+	return nil
+}
+
+func (a *denseIndexSource[K, V]) SetMany(ctx context.Context, entries []Entry[K, V]) error {
+	for _, e := range entries {
+		if e.Index < 0 {
+			return types.ErrIndexOutOfRange
+		}
+	}
+	a.Lock()
+	for _, e := range entries {
+		a.pages(e.IsLeaf).set(uint64(e.Index), e.Value)
+	}
+	a.Unlock()
+	return nil
+}
+
+// Delete clears the values, the pages are kept allocated.
+func (a *denseIndexSource[K, V]) Delete(ctx context.Context, keys []Key[K]) error {
+	a.Lock()
+	for _, key := range keys {
+		if key.Index >= 0 {
+			a.pages(key.IsLeaf).remove(uint64(key.Index))
+		}
+	}
+	a.Unlock()
+	return nil
+}
+
+func (a *denseIndexSource[K, V]) Get(ctx context.Context, isLeaf bool, index K) (res V, err error) {
+	if index < 0 {
+		return res, types.ErrKeyNotFound
+	}
+	var ok bool
+	a.RLock()
+	res, ok = a.pages(isLeaf).get(uint64(index))
+	a.RUnlock()
+	if !ok {
+		return res, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+func (a *denseIndexSource[K, V]) GetMany(ctx context.Context, keys []Key[K]) ([]V, error) {
+	res := make([]V, len(keys))
+	a.RLock()
+	defer a.RUnlock()
+	for i, key := range keys {
+		if key.Index < 0 {
+			return nil, types.ErrKeyNotFound
+		}
+		v, ok := a.pages(key.IsLeaf).get(uint64(key.Index))
+		if !ok {
+			return nil, types.ErrKeyNotFound
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (a *denseIndexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	return scanChunks(ctx, a.RLocker(), from, to, func(i K) (V, bool) {
+		return a.leafs.get(uint64(i))
+	}, fn)
+}
+
+func (a *denseIndexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	err = types.ErrKeyNotFound
+	a.RLock()
+	a.leafs.scan(func(i uint64, value V) bool {
+		if value == leaf {
+			res, err = K(i), nil
+			return false
+		}
+		return true
+	})
+	a.RUnlock()
+	return res, err
+}
+
+func (a *denseIndexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) (res []K, err error) {
+	a.RLock()
+	a.leafs.scan(func(i uint64, value V) bool {
+		if value == leaf {
+			res = append(res, K(i))
+		}
+		return true
+	})
+	a.RUnlock()
+	if len(res) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+func (a *denseIndexSource[K, V]) MemoryUsage() uint64 {
+	a.RLock()
+	defer a.RUnlock()
+	return a.leafs.memoryUsage() + a.nodes.memoryUsage()
+}
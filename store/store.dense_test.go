@@ -0,0 +1,86 @@
+package store_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDenseIndexSource_SetAndGet(t *testing.T) {
+	ctx := context.Background()
+	source := store.DenseIndexSource[uint32, types.Hash256](4)
+
+	for i := uint32(0); i < 10; i++ {
+		assert.NoError(t, source.Set(ctx, true, i, types.Hash256{byte(i)}))
+		assert.NoError(t, source.Set(ctx, false, i*3, types.Hash256{byte(i), 1}))
+	}
+
+	for i := uint32(0); i < 10; i++ {
+		res, err := source.Get(ctx, true, i)
+		assert.NoError(t, err)
+		assert.Equal(t, types.Hash256{byte(i)}, res)
+
+		res, err = source.Get(ctx, false, i*3)
+		assert.NoError(t, err)
+		assert.Equal(t, types.Hash256{byte(i), 1}, res)
+	}
+
+	_, err := source.Get(ctx, true, 10)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "leaf beyond the pages should not be found")
+	_, err = source.Get(ctx, false, 1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "node which was not set should not be found")
+	_, err = source.Get(ctx, false, 1000)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	leafIndex, err := source.LeafIndex(ctx, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), leafIndex)
+}
+
+func TestDenseIndexSource_ZeroValue(t *testing.T) {
+	ctx := context.Background()
+	source := store.DenseIndexSource[uint64, uint64](0)
+
+	assert.NoError(t, source.Set(ctx, true, 5, 0))
+	res, err := source.Get(ctx, true, 5)
+	assert.NoError(t, err, "zero value should be stored")
+	assert.Equal(t, uint64(0), res)
+
+	_, err = source.Get(ctx, true, 4)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound, "zero value should not be returned for an unset index")
+}
+
+func TestDenseIndexSource_NegativeIndex(t *testing.T) {
+	ctx := context.Background()
+	source := store.DenseIndexSource[int32, types.Hash256](16)
+
+	assert.ErrorIs(t, source.Set(ctx, true, -1, types.Hash256{1}), types.ErrIndexOutOfRange)
+	_, err := source.Get(ctx, true, -1)
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestDenseIndexSource_LeafIndexes(t *testing.T) {
+	testLeafIndexes(t, store.DenseIndexSource[uint32, types.Hash256](2))
+}
+
+func TestDenseIndexSource_MemoryUsage(t *testing.T) {
+	ctx := context.Background()
+	source := store.DenseIndexSource[uint32, types.Hash256](1024)
+	usage, ok := source.(store.IMemoryUsage)
+	if !assert.True(t, ok, "dense source should report the memory usage") {
+		return
+	}
+	assert.Equal(t, uint64(0), usage.MemoryUsage())
+
+	assert.NoError(t, source.Set(ctx, true, 0, types.Hash256{1}))
+	onePage := usage.MemoryUsage()
+	assert.Greater(t, onePage, uint64(1024*32))
+
+	assert.NoError(t, source.Set(ctx, true, 1023, types.Hash256{1}))
+	assert.Equal(t, onePage, usage.MemoryUsage(), "values within the page should not allocate")
+
+	assert.NoError(t, source.Set(ctx, true, 1024, types.Hash256{1}))
+	assert.Greater(t, usage.MemoryUsage(), 2*onePage-1024, "next page should be allocated")
+}
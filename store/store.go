@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/dk-open/go-mmr/merkle/index"
 	"github.com/dk-open/go-mmr/types"
+	"sync"
 )
 
 type IIndexSource[K index.Value, V types.HashType] interface {
@@ -11,3 +14,227 @@ type IIndexSource[K index.Value, V types.HashType] interface {
 	Set(ctx context.Context, isLeaf bool, index K, value V) error
 	LeafIndex(ctx context.Context, leaf V) (K, error)
 }
+
+// ILeafIndexes is implemented by the sources which can return every index of a duplicated leaf.
+type ILeafIndexes[K index.Value, V types.HashType] interface {
+	LeafIndexes(ctx context.Context, leaf V) ([]K, error)
+}
+
+// ISizeSource is implemented by the persistent sources which keep the size of the MMR,
+// so it can be reopened with merkle.OpenMountainRange.
+type ISizeSource[K index.Value] interface {
+	Size(ctx context.Context) (K, error)
+	SetSize(ctx context.Context, size K) error
+}
+
+// SizeConflictError is returned by the conditional writes when the size is not the expected one.
+// It matches types.ErrSizeConflict with errors.Is.
+type SizeConflictError[K index.Value] struct {
+	Expected K
+	Actual   K
+}
+
+func (e *SizeConflictError[K]) Error() string {
+	return fmt.Sprintf("size conflict: expected %d, actual %d", e.Expected, e.Actual)
+}
+
+func (e *SizeConflictError[K]) Is(target error) bool {
+	return target == types.ErrSizeConflict
+}
+
+// IConditionalSetter is implemented by the persistent sources which can check the size and write the entries
+// atomically, so several processes sharing the source can't interleave their appends.
+type IConditionalSetter[K index.Value, V types.HashType] interface {
+	// SetManyIfSize writes the entries and the new size if the stored size is expected,
+	// otherwise nothing is written and *SizeConflictError is returned.
+	SetManyIfSize(ctx context.Context, expected, size K, entries []Entry[K, V]) error
+}
+
+// Key identifies a leaf or a node in the source.
+type Key[K index.Value] struct {
+	IsLeaf bool
+	Index  K
+}
+
+// Entry is a value stored at the key.
+type Entry[K index.Value, V types.HashType] struct {
+	Key[K]
+	Value V
+}
+
+// IBatchGetter is implemented by the sources which can read many values in a single round-trip.
+type IBatchGetter[K index.Value, V types.HashType] interface {
+	// GetMany returns the values in the order of the keys, or ErrKeyNotFound if any of them is missing.
+	GetMany(ctx context.Context, keys []Key[K]) ([]V, error)
+}
+
+// IBatchSetter is implemented by the sources which can write many values in a single round-trip.
+type IBatchSetter[K index.Value, V types.HashType] interface {
+	SetMany(ctx context.Context, entries []Entry[K, V]) error
+}
+
+// IDeleter is implemented by the sources which can remove values, the pruning compaction requires it.
+type IDeleter[K index.Value] interface {
+	// Delete removes the values of the keys, the keys which are not set are ignored.
+	Delete(ctx context.Context, keys []Key[K]) error
+}
+
+// IRangeScanner is implemented by the sources which can stream the leaves in the order of the indexes.
+type IRangeScanner[K index.Value, V types.HashType] interface {
+	// ScanLeaves calls fn for the leaves from from to to (exclusive) in ascending order until fn returns false.
+	// The leaves which are not set are skipped, fn is never called while the source holds a lock.
+	ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error
+}
+
+// scanChunk is the number of leaves read at once by the scans.
+const scanChunk = 256
+
+// GetMany reads the values with IBatchGetter when the source implements it, or one by one otherwise.
+func GetMany[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], keys []Key[K]) ([]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if s, ok := source.(IBatchGetter[K, V]); ok {
+		return s.GetMany(ctx, keys)
+	}
+	res := make([]V, len(keys))
+	for i, key := range keys {
+		v, err := source.Get(ctx, key.IsLeaf, key.Index)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// SetMany writes the values with IBatchSetter when the source implements it, or one by one otherwise.
+func SetMany[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], entries []Entry[K, V]) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if s, ok := source.(IBatchSetter[K, V]); ok {
+		return s.SetMany(ctx, entries)
+	}
+	for _, e := range entries {
+		if err := source.Set(ctx, e.IsLeaf, e.Index, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the values with IDeleter, or returns ErrNotSupported when the source can't remove values.
+func Delete[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], keys []Key[K]) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if s, ok := source.(IDeleter[K]); ok {
+		return s.Delete(ctx, keys)
+	}
+	return types.ErrNotSupported
+}
+
+// Size reads the size with ISizeSource, or returns ErrNotSupported when the source does not keep the size.
+func Size[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V]) (res K, err error) {
+	if s, ok := source.(ISizeSource[K]); ok {
+		return s.Size(ctx)
+	}
+	return res, types.ErrNotSupported
+}
+
+// SetSize writes the size with ISizeSource, or returns ErrNotSupported when the source does not keep the size.
+func SetSize[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], size K) error {
+	if s, ok := source.(ISizeSource[K]); ok {
+		return s.SetSize(ctx, size)
+	}
+	return types.ErrNotSupported
+}
+
+// SetManyIfSize writes the entries with IConditionalSetter, or returns ErrNotSupported when the source
+// can't check the size and write the entries atomically.
+func SetManyIfSize[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], expected, size K, entries []Entry[K, V]) error {
+	if s, ok := source.(IConditionalSetter[K, V]); ok {
+		return s.SetManyIfSize(ctx, expected, size, entries)
+	}
+	return types.ErrNotSupported
+}
+
+// ScanLeaves streams the leaves with IRangeScanner when the source implements it,
+// or reads them in chunks with GetMany otherwise.
+func ScanLeaves[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], from, to K, fn func(i K, leaf V) bool) error {
+	from = max(from, 0)
+	if from >= to {
+		return nil
+	}
+	if s, ok := source.(IRangeScanner[K, V]); ok {
+		return s.ScanLeaves(ctx, from, to, fn)
+	}
+
+	keys := make([]Key[K], 0, scanChunk)
+	for start := from; start < to; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		keys = keys[:0]
+		for i := start; i < to && len(keys) < scanChunk; i++ {
+			keys = append(keys, Key[K]{IsLeaf: true, Index: i})
+		}
+		start += K(len(keys))
+
+		values, err := GetMany(ctx, source, keys)
+		if errors.Is(err, types.ErrKeyNotFound) {
+			// Some of the leaves are missing, e.g. pruned, read the chunk one by one to skip them
+			for _, key := range keys {
+				v, gErr := source.Get(ctx, true, key.Index)
+				if errors.Is(gErr, types.ErrKeyNotFound) {
+					continue
+				}
+				if gErr != nil {
+					return gErr
+				}
+				if !fn(key.Index, v) {
+					return nil
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			if !fn(keys[i].Index, v) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// scanChunks reads the leaves with get in chunks under the lock and calls fn for them once the lock is released.
+func scanChunks[K index.Value, V types.HashType](ctx context.Context, lock sync.Locker, from, to K, get func(K) (V, bool), fn func(i K, leaf V) bool) error {
+	indexes := make([]K, 0, scanChunk)
+	values := make([]V, 0, scanChunk)
+	for start := max(from, 0); start < to; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		indexes, values = indexes[:0], values[:0]
+		end := start
+		lock.Lock()
+		for ; end < to && end-start < scanChunk; end++ {
+			if v, ok := get(end); ok {
+				indexes = append(indexes, end)
+				values = append(values, v)
+			}
+		}
+		lock.Unlock()
+		for i, v := range values {
+			if !fn(indexes[i], v) {
+				return nil
+			}
+		}
+		start = end
+	}
+	return nil
+}
@@ -9,31 +9,93 @@ import (
 
 type memoryIndexSource[K index.Value, V types.HashType] struct {
 	sync.RWMutex
-	leafs map[K]V
-	nodes map[K]V
+	leafs     map[K]V
+	nodes     map[K]V
+	positions leafPositions[K, V]
 }
 
 func (a *memoryIndexSource[K, V]) Set(ctx context.Context, isLeaf bool, index K, value V) error {
 	a.Lock()
+	a.set(isLeaf, index, value)
+	a.Unlock()
+	return nil
+}
+
+func (a *memoryIndexSource[K, V]) SetMany(ctx context.Context, entries []Entry[K, V]) error {
+	a.Lock()
+	for _, e := range entries {
+		a.set(e.IsLeaf, e.Index, e.Value)
+	}
+	a.Unlock()
+	return nil
+}
+
+func (a *memoryIndexSource[K, V]) set(isLeaf bool, index K, value V) {
 	if isLeaf {
+		prev, ok := a.leafs[index]
 		a.leafs[index] = value
+		a.positions.set(index, prev, ok, value)
 	} else {
 		a.nodes[index] = value
 	}
+}
+
+func (a *memoryIndexSource[K, V]) Delete(ctx context.Context, keys []Key[K]) error {
+	a.Lock()
+	for _, key := range keys {
+		if !key.IsLeaf {
+			delete(a.nodes, key.Index)
+		} else if prev, ok := a.leafs[key.Index]; ok {
+			delete(a.leafs, key.Index)
+			a.positions.remove(key.Index, prev)
+		}
+	}
 	a.Unlock()
 	return nil
 }
 
 func (a *memoryIndexSource[K, V]) Get(ctx context.Context, isLeaf bool, index K) (V, error) {
-	var res V
-	var ok bool
 	a.RLock()
+	res, ok := a.get(isLeaf, index)
+	a.RUnlock()
+	if !ok {
+		return res, types.ErrKeyNotFound
+	}
+	return res, nil
+}
+
+func (a *memoryIndexSource[K, V]) GetMany(ctx context.Context, keys []Key[K]) ([]V, error) {
+	res := make([]V, len(keys))
+	a.RLock()
+	defer a.RUnlock()
+	for i, key := range keys {
+		v, ok := a.get(key.IsLeaf, key.Index)
+		if !ok {
+			return nil, types.ErrKeyNotFound
+		}
+		res[i] = v
+	}
+	return res, nil
+}
 
+func (a *memoryIndexSource[K, V]) get(isLeaf bool, index K) (res V, ok bool) {
 	if isLeaf {
 		res, ok = a.leafs[index]
 	} else {
 		res, ok = a.nodes[index]
 	}
+	return
+}
+
+func (a *memoryIndexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	return scanChunks(ctx, a.RLocker(), from, to, func(i K) (V, bool) {
+		return a.get(true, i)
+	}, fn)
+}
+
+func (a *memoryIndexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	a.RLock()
+	res, ok := a.positions.first(leaf)
 	a.RUnlock()
 	if !ok {
 		return res, types.ErrKeyNotFound
@@ -41,20 +103,20 @@ func (a *memoryIndexSource[K, V]) Get(ctx context.Context, isLeaf bool, index K)
 	return res, nil
 }
 
-func (a *memoryIndexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+func (a *memoryIndexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
 	a.RLock()
-	defer a.RUnlock()
-	for k, v := range a.leafs {
-		if v == leaf {
-			return k, nil
-		}
+	res := a.positions.all(leaf)
+	a.RUnlock()
+	if len(res) == 0 {
+		return nil, types.ErrKeyNotFound
 	}
-	return res, types.ErrKeyNotFound
+	return res, nil
 }
 
 func MemoryIndexSource[K index.Value, V types.HashType]() IIndexSource[K, V] {
 	return &memoryIndexSource[K, V]{
-		leafs: make(map[K]V),
-		nodes: make(map[K]V),
+		leafs:     make(map[K]V),
+		nodes:     make(map[K]V),
+		positions: make(leafPositions[K, V]),
 	}
 }
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/dk-open/go-mmr/merkle/index"
+	"github.com/dk-open/go-mmr/types"
+	"slices"
+	"sync"
+)
+
+// leafPositions is a reverse index from the leaf hash to the sorted list of the leaf indexes.
+type leafPositions[K index.Value, V types.HashType] map[V][]K
+
+// set moves the index from the previous hash of the leaf to the new one.
+func (p leafPositions[K, V]) set(index K, prev V, hasPrev bool, value V) {
+	if hasPrev {
+		p.remove(index, prev)
+	}
+	p.add(index, value)
+}
+
+// add adds the index to the hash of the leaf.
+func (p leafPositions[K, V]) add(index K, value V) {
+	positions := p[value]
+	if i, found := slices.BinarySearch(positions, index); !found {
+		p[value] = slices.Insert(positions, i, index)
+	}
+}
+
+func (p leafPositions[K, V]) remove(index K, value V) {
+	positions := p[value]
+	if i, found := slices.BinarySearch(positions, index); found {
+		positions = slices.Delete(positions, i, i+1)
+		if len(positions) == 0 {
+			delete(p, value)
+		} else {
+			p[value] = positions
+		}
+	}
+}
+
+// first returns the lowest index of the leaf.
+func (p leafPositions[K, V]) first(value V) (res K, ok bool) {
+	if positions := p[value]; len(positions) > 0 {
+		return positions[0], true
+	}
+	return res, false
+}
+
+// all returns a copy of the indexes of the leaf.
+func (p leafPositions[K, V]) all(value V) []K {
+	return slices.Clone(p[value])
+}
+
+type leafIndexSource[K index.Value, V types.HashType] struct {
+	IIndexSource[K, V]
+	sync.RWMutex
+	// positions may keep the indexes of the overwritten and deleted leaves, the lookups check them in the source.
+	positions leafPositions[K, V]
+}
+
+// LeafIndexed wraps the source with an in-memory reverse index of the leaf hashes, so LeafIndex and LeafIndexes are O(1).
+// The index is seeded with the leaves of the source below size, usually the size of the MMR over it, and then
+// filled from the leaves set through the wrapper. The writes don't read the source: a lookup reads the leaves
+// it finds to skip the overwritten ones, and falls back to the source when none is left.
+func LeafIndexed[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], size K) (IIndexSource[K, V], error) {
+	res := &leafIndexSource[K, V]{
+		IIndexSource: source,
+		positions:    make(leafPositions[K, V]),
+	}
+	if err := ScanLeaves(ctx, source, 0, size, func(i K, leaf V) bool {
+		res.positions.add(i, leaf)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *leafIndexSource[K, V]) Set(ctx context.Context, isLeaf bool, index K, value V) error {
+	if err := s.IIndexSource.Set(ctx, isLeaf, index, value); err != nil || !isLeaf {
+		return err
+	}
+	s.Lock()
+	s.positions.add(index, value)
+	s.Unlock()
+	return nil
+}
+
+func (s *leafIndexSource[K, V]) GetMany(ctx context.Context, keys []Key[K]) ([]V, error) {
+	return GetMany(ctx, s.IIndexSource, keys)
+}
+
+func (s *leafIndexSource[K, V]) SetMany(ctx context.Context, entries []Entry[K, V]) error {
+	if err := SetMany(ctx, s.IIndexSource, entries); err != nil {
+		return err
+	}
+	s.index(entries)
+	return nil
+}
+
+// SetManyIfSize forwards the conditional write, the index is updated only when the source accepts it.
+func (s *leafIndexSource[K, V]) SetManyIfSize(ctx context.Context, expected, size K, entries []Entry[K, V]) error {
+	if err := SetManyIfSize(ctx, s.IIndexSource, expected, size, entries); err != nil {
+		return err
+	}
+	s.index(entries)
+	return nil
+}
+
+// Delete keeps the deleted leaves in the index, the lookups skip them.
+func (s *leafIndexSource[K, V]) Delete(ctx context.Context, keys []Key[K]) error {
+	return Delete(ctx, s.IIndexSource, keys)
+}
+
+// index adds the written leaves of the entries to the index.
+func (s *leafIndexSource[K, V]) index(entries []Entry[K, V]) {
+	s.Lock()
+	defer s.Unlock()
+	for _, e := range entries {
+		if e.IsLeaf {
+			s.positions.add(e.Index, e.Value)
+		}
+	}
+}
+
+func (s *leafIndexSource[K, V]) Size(ctx context.Context) (K, error) {
+	return Size(ctx, s.IIndexSource)
+}
+
+func (s *leafIndexSource[K, V]) SetSize(ctx context.Context, size K) error {
+	return SetSize(ctx, s.IIndexSource, size)
+}
+
+func (s *leafIndexSource[K, V]) ScanLeaves(ctx context.Context, from, to K, fn func(i K, leaf V) bool) error {
+	return ScanLeaves(ctx, s.IIndexSource, from, to, fn)
+}
+
+func (s *leafIndexSource[K, V]) LeafIndex(ctx context.Context, leaf V) (res K, err error) {
+	s.RLock()
+	candidates := s.positions.all(leaf)
+	s.RUnlock()
+	for _, i := range candidates {
+		ok, err := s.holds(ctx, i, leaf)
+		if err != nil {
+			return res, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return s.IIndexSource.LeafIndex(ctx, leaf)
+}
+
+func (s *leafIndexSource[K, V]) LeafIndexes(ctx context.Context, leaf V) ([]K, error) {
+	s.RLock()
+	candidates := s.positions.all(leaf)
+	s.RUnlock()
+	res := candidates[:0]
+	for _, i := range candidates {
+		ok, err := s.holds(ctx, i, leaf)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, i)
+		}
+	}
+	if len(res) > 0 {
+		return res, nil
+	}
+	return LeafIndexes(ctx, s.IIndexSource, leaf)
+}
+
+// holds reports whether the leaf is still at the index in the source.
+func (s *leafIndexSource[K, V]) holds(ctx context.Context, index K, leaf V) (bool, error) {
+	v, err := s.IIndexSource.Get(ctx, true, index)
+	if errors.Is(err, types.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil && v == leaf, err
+}
+
+// LeafIndexes returns all indexes of the leaf, using ILeafIndexes when the source implements it.
+func LeafIndexes[K index.Value, V types.HashType](ctx context.Context, source IIndexSource[K, V], leaf V) ([]K, error) {
+	if s, ok := source.(ILeafIndexes[K, V]); ok {
+		return s.LeafIndexes(ctx, leaf)
+	}
+	res, err := source.LeafIndex(ctx, leaf)
+	if err != nil {
+		return nil, err
+	}
+	return []K{res}, nil
+}
@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"context"
+	"github.com/dk-open/go-mmr/store"
+	"github.com/dk-open/go-mmr/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// scanIndexSource hides the reverse index of the memory source to check the fallback of the decorator.
+type scanIndexSource struct {
+	store.IIndexSource[uint32, types.Hash256]
+}
+
+func testLeafIndexes(t *testing.T, source store.IIndexSource[uint32, types.Hash256]) {
+	ctx := context.Background()
+	dup := types.Hash256{1}
+	other := types.Hash256{2}
+
+	assert.NoError(t, source.Set(ctx, true, 3, dup))
+	assert.NoError(t, source.Set(ctx, true, 0, dup))
+	assert.NoError(t, source.Set(ctx, true, 1, other))
+	assert.NoError(t, source.Set(ctx, true, 2, dup))
+	assert.NoError(t, source.Set(ctx, false, 5, other), "nodes should not be indexed")
+
+	leafIndex, err := source.LeafIndex(ctx, dup)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), leafIndex, "LeafIndex should return the lowest index")
+
+	indexes, err := store.LeafIndexes(ctx, source, dup)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0, 2, 3}, indexes)
+
+	indexes, err = store.LeafIndexes(ctx, source, other)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1}, indexes)
+
+	// Overwriting the leaf moves it to the new hash.
+	assert.NoError(t, source.Set(ctx, true, 0, other))
+	indexes, err = store.LeafIndexes(ctx, source, dup)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3}, indexes)
+	indexes, err = store.LeafIndexes(ctx, source, other)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0, 1}, indexes)
+
+	_, err = source.LeafIndex(ctx, types.Hash256{9})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+	_, err = store.LeafIndexes(ctx, source, types.Hash256{9})
+	assert.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestMemoryIndexSource_LeafIndexes(t *testing.T) {
+	testLeafIndexes(t, store.MemoryIndexSource[uint32, types.Hash256]())
+}
+
+// leafIndexed wraps the source with the leaf index seeded with the leaves below size.
+func leafIndexed(t *testing.T, source store.IIndexSource[uint32, types.Hash256], size uint32) store.IIndexSource[uint32, types.Hash256] {
+	res, err := store.LeafIndexed(context.Background(), source, size)
+	assert.NoError(t, err)
+	return res
+}
+
+func TestLeafIndexed(t *testing.T) {
+	testLeafIndexes(t, leafIndexed(t, &scanIndexSource{store.MemoryIndexSource[uint32, types.Hash256]()}, 0))
+}
+
+func TestLeafIndexed_Seeded(t *testing.T) {
+	ctx := context.Background()
+	source := store.MemoryIndexSource[uint32, types.Hash256]()
+	assert.NoError(t, source.Set(ctx, true, 7, types.Hash256{7}))
+
+	// The leaves written before wrapping are indexed by the scan, so the lowest index is found
+	// when the same hash is written again.
+	indexed := leafIndexed(t, &scanIndexSource{source}, 8)
+	assert.NoError(t, indexed.Set(ctx, true, 9, types.Hash256{7}))
+	leafIndex, err := indexed.LeafIndex(ctx, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), leafIndex)
+
+	indexes, err := store.LeafIndexes(ctx, indexed, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{7, 9}, indexes)
+}
+
+func TestLeafIndexed_Fallback(t *testing.T) {
+	ctx := context.Background()
+	source := store.MemoryIndexSource[uint32, types.Hash256]()
+	assert.NoError(t, source.Set(ctx, true, 7, types.Hash256{7}))
+
+	// Leaves above the seeded size are found through the wrapped source.
+	indexed := leafIndexed(t, source, 0)
+	leafIndex, err := indexed.LeafIndex(ctx, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), leafIndex)
+
+	indexes, err := store.LeafIndexes(ctx, indexed, types.Hash256{7})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{7}, indexes)
+}
+
+// deletingIndexSource is countingIndexSource which also forwards the deletes.
+type deletingIndexSource struct {
+	*countingIndexSource
+}
+
+func (s deletingIndexSource) Delete(ctx context.Context, keys []store.Key[uint32]) error {
+	return store.Delete(ctx, s.IIndexSource, keys)
+}
+
+func TestLeafIndexed_WritesDoNotRead(t *testing.T) {
+	ctx := context.Background()
+	source := &countingIndexSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+	indexed := leafIndexed(t, deletingIndexSource{source}, 0)
+	leaf := func(i uint32, v byte) store.Entry[uint32, types.Hash256] {
+		return store.Entry[uint32, types.Hash256]{Key: store.Key[uint32]{IsLeaf: true, Index: i}, Value: types.Hash256{v}}
+	}
+
+	assert.NoError(t, indexed.Set(ctx, true, 0, types.Hash256{1}))
+	assert.NoError(t, store.SetMany(ctx, indexed, []store.Entry[uint32, types.Hash256]{leaf(1, 1), leaf(0, 2), leaf(2, 2)}))
+	assert.NoError(t, store.Delete(ctx, indexed, []store.Key[uint32]{{IsLeaf: true, Index: 2}}))
+	assert.Equal(t, 0, source.gets, "the writes should not read the source")
+
+	indexes, err := store.LeafIndexes(ctx, indexed, types.Hash256{1})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1}, indexes, "overwritten leaf should be skipped")
+	indexes, err = store.LeafIndexes(ctx, indexed, types.Hash256{2})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0}, indexes, "deleted leaf should be skipped")
+}
@@ -81,3 +81,103 @@ func TestMemoryIndexSource_OverwriteValue(t *testing.T) {
 	assert.NoError(t, err, "Get leaf value should not return an error")
 	assert.Equal(t, value2, res, "Get should return the overwritten leaf value")
 }
+
+func TestDelete(t *testing.T) {
+	sources := map[string]func() store.IIndexSource[uint32, types.Hash256]{
+		"memory": store.MemoryIndexSource[uint32, types.Hash256],
+		"dense": func() store.IIndexSource[uint32, types.Hash256] {
+			return store.DenseIndexSource[uint32, types.Hash256](64)
+		},
+		"leafIndexed": func() store.IIndexSource[uint32, types.Hash256] {
+			res, _ := store.LeafIndexed(context.Background(), store.DenseIndexSource[uint32, types.Hash256](64), 0)
+			return res
+		},
+		"cached": func() store.IIndexSource[uint32, types.Hash256] {
+			return store.Cached(store.MemoryIndexSource[uint32, types.Hash256](), 16)
+		},
+	}
+	for name, newSource := range sources {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newSource()
+			assert.NoError(t, source.Set(ctx, true, 1, types.Hash256{1}))
+			assert.NoError(t, source.Set(ctx, true, 2, types.Hash256{1}))
+			assert.NoError(t, source.Set(ctx, false, 1, types.Hash256{2}))
+
+			assert.NoError(t, store.Delete(ctx, source, []store.Key[uint32]{
+				{IsLeaf: true, Index: 1},
+				{IsLeaf: false, Index: 1},
+				{IsLeaf: false, Index: 100},
+			}), "missing keys should be ignored")
+
+			_, err := source.Get(ctx, true, 1)
+			assert.ErrorIs(t, err, types.ErrKeyNotFound)
+			_, err = source.Get(ctx, false, 1)
+			assert.ErrorIs(t, err, types.ErrKeyNotFound)
+			res, err := source.Get(ctx, true, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, types.Hash256{1}, res)
+
+			indexes, err := store.LeafIndexes(ctx, source, types.Hash256{1})
+			assert.NoError(t, err)
+			assert.Equal(t, []uint32{2}, indexes, "deleted leaf should not be found by hash")
+		})
+	}
+}
+
+func TestDelete_NotSupported(t *testing.T) {
+	source := &countingIndexSource{IIndexSource: store.MemoryIndexSource[uint32, types.Hash256]()}
+	err := store.Delete(context.Background(), store.IIndexSource[uint32, types.Hash256](source), []store.Key[uint32]{{IsLeaf: true}})
+	assert.ErrorIs(t, err, types.ErrNotSupported)
+}
+
+func TestScanLeaves(t *testing.T) {
+	sources := map[string]func() store.IIndexSource[uint32, types.Hash256]{
+		"memory": store.MemoryIndexSource[uint32, types.Hash256],
+		"dense": func() store.IIndexSource[uint32, types.Hash256] {
+			return store.DenseIndexSource[uint32, types.Hash256](64)
+		},
+		"cached": func() store.IIndexSource[uint32, types.Hash256] {
+			return store.Cached(store.MemoryIndexSource[uint32, types.Hash256](), 16)
+		},
+		"fallback": func() store.IIndexSource[uint32, types.Hash256] {
+			return &countingIndexSource{IIndexSource: store.DenseIndexSource[uint32, types.Hash256](64)}
+		},
+	}
+	for name, newSource := range sources {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newSource()
+			for i := uint32(0); i < 600; i++ {
+				if i != 300 {
+					assert.NoError(t, source.Set(ctx, true, i, types.Hash256{byte(i), byte(i >> 8)}))
+				}
+			}
+			assert.NoError(t, source.Set(ctx, false, 5, types.Hash256{}), "nodes should not be scanned")
+
+			var indexes []uint32
+			assert.NoError(t, store.ScanLeaves(ctx, source, 10, 700, func(i uint32, leaf types.Hash256) bool {
+				assert.Equal(t, types.Hash256{byte(i), byte(i >> 8)}, leaf)
+				indexes = append(indexes, i)
+				return true
+			}))
+			assert.Len(t, indexes, 589, "missing leaf should be skipped")
+			assert.Equal(t, uint32(10), indexes[0])
+			assert.Equal(t, uint32(599), indexes[len(indexes)-1])
+			for i := 1; i < len(indexes); i++ {
+				assert.Less(t, indexes[i-1], indexes[i])
+			}
+
+			indexes = nil
+			assert.NoError(t, store.ScanLeaves(ctx, source, 0, 600, func(i uint32, leaf types.Hash256) bool {
+				indexes = append(indexes, i)
+				return len(indexes) < 3
+			}))
+			assert.Equal(t, []uint32{0, 1, 2}, indexes, "scan should stop when fn returns false")
+
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			assert.ErrorIs(t, store.ScanLeaves(canceled, source, 0, 600, func(uint32, types.Hash256) bool { return true }), context.Canceled)
+		})
+	}
+}
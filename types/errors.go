@@ -3,6 +3,10 @@ package types
 import "errors"
 
 var (
-	ErrKeyNotFound  = errors.New("Key not found")
-	ErrTypeMismatch = errors.New("Type mismatch")
+	ErrKeyNotFound      = errors.New("Key not found")
+	ErrTypeMismatch     = errors.New("Type mismatch")
+	ErrIndexOutOfRange  = errors.New("Index out of range")
+	ErrNotSupported     = errors.New("Not supported")
+	ErrSizeConflict     = errors.New("Size conflict")
+	ErrCapacityExceeded = errors.New("Capacity exceeded")
 )
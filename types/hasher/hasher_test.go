@@ -1,8 +1,11 @@
 package hasher_test
 
 import (
+	"crypto/sha256"
+	"github.com/dk-open/go-mmr/types"
 	"github.com/dk-open/go-mmr/types/hasher"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
 	"testing"
 )
 
@@ -75,3 +78,18 @@ func TestBlake3(t *testing.T) {
 	actual := hasher.Blake3(value)
 	assert.Equal(t, expected, actual, "Blake3 hash should be equal")
 }
+
+func TestPooledStates(t *testing.T) {
+	// The values are hashed in the reverse order, a reused state must not keep the previous input
+	expected := sha256.Sum256([]byte("second first"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, types.Hash256(expected), hasher.Sha256([]byte("first"), []byte("second ")))
+	}
+	assert.Equal(t, types.Hash256(sha3.Sum256([]byte("test data"))), hasher.Sha3_256([]byte("test data")))
+
+	value := []byte("test data")
+	allocs := testing.AllocsPerRun(100, func() {
+		hasher.Blake3(value)
+	})
+	assert.Zero(t, allocs, "hashing should not allocate")
+}
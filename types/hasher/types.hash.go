@@ -10,39 +10,61 @@ import (
 	"golang.org/x/crypto/ripemd160"
 	"golang.org/x/crypto/sha3"
 	"hash"
+	"sync"
+)
+
+// The hash states are pooled, so a hash call does not allocate the state and the sum.
+var (
+	sha256Pool      = newPool(sha256.New)
+	sha512Pool      = newPool(sha512.New)
+	sha3_256Pool    = newPool(sha3.New256)
+	sha3_384Pool    = newPool(sha3.New384)
+	sha3_512Pool    = newPool(sha3.New512)
+	blake2b_256Pool = newPool(blake2b.New256)
+	blake2b_512Pool = newPool(blake2b.New512)
+	ripemd160Pool   = newPool(ripemd160.New)
+	blake3Pool      = newPool(func() hash.Hash { return blake3.New() })
 )
 
 // Sha256 creates a Hasher for SHA-256.
-func Sha256(values ...[]byte) types.Hash256 {
-	return types.Hash256(sumHashes(sha256.New(), values...))
+func Sha256(values ...[]byte) (res types.Hash256) {
+	sumHashes(sha256Pool, res[:], values...)
+	return res
 }
 
-func Sha512(values ...[]byte) types.Hash512 {
-	return types.Hash512(sumHashes(sha512.New(), values...))
+func Sha512(values ...[]byte) (res types.Hash512) {
+	sumHashes(sha512Pool, res[:], values...)
+	return res
 }
 
-func Sha3_256(values ...[]byte) types.Hash256 {
-	return types.Hash256(sumHashes(sha3.New256(), values...))
+func Sha3_256(values ...[]byte) (res types.Hash256) {
+	sumHashes(sha3_256Pool, res[:], values...)
+	return res
 }
 
-func Sha3_384(values ...[]byte) types.Hash384 {
-	return types.Hash384(sumHashes(sha3.New384(), values...))
+func Sha3_384(values ...[]byte) (res types.Hash384) {
+	sumHashes(sha3_384Pool, res[:], values...)
+	return res
 }
 
-func Sha3_512(values ...[]byte) types.Hash512 {
-	return types.Hash512(sumHashes(sha3.New512(), values...))
+func Sha3_512(values ...[]byte) (res types.Hash512) {
+	sumHashes(sha3_512Pool, res[:], values...)
+	return res
 }
 
-func Blake2b_256(values ...[]byte) types.Hash256 {
-	return types.Hash256(sumHashes(blake2b.New256(), values...))
+func Blake2b_256(values ...[]byte) (res types.Hash256) {
+	sumHashes(blake2b_256Pool, res[:], values...)
+	return res
 }
 
-func Blake2b_512(values ...[]byte) types.Hash512 {
-	return types.Hash512(sumHashes(blake2b.New512(), values...))
+func Blake2b_512(values ...[]byte) (res types.Hash512) {
+	sumHashes(blake2b_512Pool, res[:], values...)
+	return res
 }
 
-func Ripemd160(values ...[]byte) types.Hash160 {
-	return types.Hash160(sumHashes(ripemd160.New(), values...))
+func Ripemd160(values ...[]byte) (res types.Hash160) {
+	sumHashes(ripemd160Pool, res[:], values...)
+	return res
 }
 
 // Argon2 creates a Hasher that uses the Argon2id variant.
@@ -53,13 +75,32 @@ func Argon2(values ...[]byte) types.Hash256 {
 }
 
 // Blake3 creates a Hasher that uses BLAKE3.
-func Blake3(values ...[]byte) types.Hash256 {
-	return types.Hash256(sumHashes(blake3.New(), values...))
+func Blake3(values ...[]byte) (res types.Hash256) {
+	sumHashes(blake3Pool, res[:], values...)
+	return res
+}
+
+// pooledHash is a hash state with the buffer its sum is written to.
+type pooledHash struct {
+	h   hash.Hash
+	sum []byte
+}
+
+func newPool[H hash.Hash](f func() H) *sync.Pool {
+	return &sync.Pool{New: func() any {
+		h := f()
+		return &pooledHash{h: h, sum: make([]byte, 0, h.Size())}
+	}}
 }
 
-func sumHashes(h hash.Hash, values ...[]byte) []byte {
+// sumHashes hashes the values in the reverse order and copies the sum to dst.
+func sumHashes(pool *sync.Pool, dst []byte, values ...[]byte) {
+	p := pool.Get().(*pooledHash)
+	p.h.Reset()
 	for i := len(values) - 1; i >= 0; i-- {
-		h.Write(values[i])
+		p.h.Write(values[i])
 	}
-	return h.Sum([]byte{})
+	p.sum = p.h.Sum(p.sum[:0])
+	copy(dst, p.sum)
+	pool.Put(p)
 }
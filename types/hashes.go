@@ -57,6 +57,45 @@ func HashBytes[TH HashType](value TH) ([]byte, error) {
 	}
 }
 
+// AppendHashBytes appends the bytes of the hash to dst, the same bytes HashBytes returns, without allocating
+// when dst has enough capacity.
+func AppendHashBytes[TH HashType](dst []byte, value TH) ([]byte, error) {
+	switch v := any(value).(type) {
+	case Hash128:
+		return append(dst, v[:]...), nil
+	case Hash160:
+		return append(dst, v[:]...), nil
+	case Hash224:
+		return append(dst, v[:]...), nil
+	case Hash256:
+		return append(dst, v[:]...), nil
+	case Hash384:
+		return append(dst, v[:]...), nil
+	case Hash512:
+		return append(dst, v[:]...), nil
+	case uint16:
+		return append(dst, byte(v>>8), byte(v)), nil
+	case uint32:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case uint64:
+		return append(dst, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case int16:
+		return append(dst, byte(v>>8), byte(v)), nil
+	case int32:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case int64:
+		return append(dst, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case int:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case uint:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)), nil
+	case string:
+		return append(dst, v...), nil
+	default:
+		return nil, ErrTypeMismatch
+	}
+}
+
 // BufferWrite writes the given hash or other supported types to the buffer.
 func BufferWrite[THash HashType](buf *bytes.Buffer, hash THash) error {
 	data, err := HashBytes(hash)
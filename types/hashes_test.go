@@ -177,3 +177,25 @@ func TestBufferRead(t *testing.T) {
 		}
 	}
 }
+
+func TestAppendHashBytes(t *testing.T) {
+	prefix := []byte{0xff}
+	res, err := types.AppendHashBytes(prefix, types.Hash256{1, 2, 3})
+	assert.NoError(t, err)
+	expected, _ := types.HashBytes(types.Hash256{1, 2, 3})
+	assert.Equal(t, append([]byte{0xff}, expected...), res)
+
+	res, err = types.AppendHashBytes(res[:1], uint32(16909060))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 1, 2, 3, 4}, res)
+
+	res, err = types.AppendHashBytes(res[:0], "test")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("test"), res)
+
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = types.AppendHashBytes(buf[:0], types.Hash512{1})
+	})
+	assert.Zero(t, allocs, "appending to a buffer with enough capacity should not allocate")
+}